@@ -0,0 +1,114 @@
+package code
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// number of points needed to define a circle
+const CIRCLE_SAMPLE_SIZE int = 3
+
+// Circle3D represents a circle in 3D space
+type Circle3D struct {
+	Center Point3D
+	// unit normal of the plane of the circle
+	Normal Point3D
+	Radius float64
+}
+
+// computes the circle passing through 3 points
+// returns an error if the points are collinear
+func GetCircle(p1, p2, p3 Point3D) (Circle3D, error) {
+	if isCollinear(p1, p2, p3) {
+		return Circle3D{}, errors.New("points are collinear")
+	}
+	a := p1.Sub(p3)
+	b := p2.Sub(p3)
+	normal := a.Cross(b)
+	length2 := normal.Dot(normal)
+	// circumcenter of the triangle
+	center := p3.Add(b.Scale(a.Dot(a)).Sub(a.Scale(b.Dot(b))).Cross(normal).Scale(1 / (2 * length2)))
+	return Circle3D{Center: center, Normal: normal.Normalize(), Radius: p1.Sub(center).Norm()}, nil
+}
+
+// calculate distance of a point to the circle
+// combines the radial distance within the plane of the circle and the distance to that plane
+func (c *Circle3D) GetDistance(point *Point3D) float64 {
+	v := (*point).Sub(c.Center)
+	height := v.Dot(c.Normal)
+	radial := v.Sub(c.Normal.Scale(height)).Norm() - c.Radius
+	return math.Sqrt(radial*radial + height*height)
+}
+
+// string representation of a Circle3D
+func (c Circle3D) String() string {
+	return fmt.Sprintf("center=(%v), normal=(%v), radius=%f", c.Center, c.Normal, c.Radius)
+}
+
+// CircleModel is the Model of circles, fitted to samples of 3 points
+// circles with radius out of [MinRadius, MaxRadius] are rejected (MaxRadius 0 means no upper limit)
+type CircleModel struct {
+	MinRadius float64
+	MaxRadius float64
+}
+
+func (m CircleModel) SampleSize() int {
+	return CIRCLE_SAMPLE_SIZE
+}
+
+func (m CircleModel) IsDegenerate(sample []Point3D) bool {
+	return isCollinear(sample[0], sample[1], sample[2])
+}
+
+func (m CircleModel) Fit(sample []Point3D) (Circle3D, error) {
+	circle, err := GetCircle(sample[0], sample[1], sample[2])
+	if err != nil {
+		return circle, err
+	}
+	return circle, m.validate(circle)
+}
+
+func (m CircleModel) Residual(circle Circle3D, point *Point3D) float64 {
+	return circle.GetDistance(point)
+}
+
+// least squares circle of the points
+// the plane of the circle is fitted first, then the circle within that plane minimizing the algebraic distance
+func (m CircleModel) Refit(circle Circle3D, points []Point3D) (Circle3D, error) {
+	plane := FitPlane(points)
+	frame := plane.GetFrame()
+	// 2D coordinates relative to the centroid for numerical stability
+	cx, cy := frame.ToPlane(GetCentroid(points))
+	rows := make([][]float64, len(points))
+	rhs := make([]float64, len(points))
+	for i, point := range points {
+		x, y := frame.ToPlane(point)
+		x, y = x-cx, y-cy
+		// x^2 + y^2 = 2 a x + 2 b y + (r^2 - a^2 - b^2)
+		rows[i] = []float64{2 * x, 2 * y, 1}
+		rhs[i] = x*x + y*y
+	}
+	solution, err := solveLeastSquares(rows, rhs)
+	if err != nil {
+		return circle, err
+	}
+	radius2 := solution[2] + solution[0]*solution[0] + solution[1]*solution[1]
+	if radius2 <= 0 {
+		return circle, errors.New("invalid circle")
+	}
+	refitted := Circle3D{
+		Center: frame.FromPlane(solution[0]+cx, solution[1]+cy),
+		Normal: frame.Normal,
+		Radius: math.Sqrt(radius2),
+	}
+	return refitted, m.validate(refitted)
+}
+
+// checks the radius constraints
+func (m CircleModel) validate(circle Circle3D) error {
+	if circle.Radius < m.MinRadius || (m.MaxRadius > 0 && circle.Radius > m.MaxRadius) {
+		return errors.New("circle radius out of range")
+	}
+	return nil
+}
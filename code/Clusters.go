@@ -0,0 +1,124 @@
+package code
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// OrientedBox is a bounding box aligned with the principal axes of a set of points
+type OrientedBox struct {
+	Center Point3D
+	// unit axes of the box, in increasing order of spread of the points
+	Axes [3]Point3D
+	// length of the box along each axis
+	Extents [3]float64
+}
+
+// Cluster is a spatially connected group of points
+type Cluster struct {
+	Points   []Point3D
+	Size     int
+	Centroid Point3D
+	// axis-aligned bounding box
+	Min Point3D
+	Max Point3D
+	// oriented bounding box
+	Box OrientedBox
+}
+
+// extracts clusters of points where each point is within tolerance of another point of the same cluster
+// clusters with fewer than minSize points or more than maxSize points are discarded (maxSize 0 means no limit)
+// clusters are returned in decreasing order of size
+func (pointCloud *PointCloud) EuclideanClusters(tolerance float64, minSize, maxSize int) []Cluster {
+	clusters := []Cluster{}
+	if tolerance <= 0 || len(pointCloud.points) == 0 {
+		return clusters
+	}
+
+	// spatial index used for the neighbourhood queries
+	tree := NewKDTree(pointCloud.points)
+	processed := make([]bool, len(pointCloud.points))
+
+	for i := range pointCloud.points {
+		if processed[i] {
+			continue
+		}
+		processed[i] = true
+		// grow the cluster from point i
+		members := []int{i}
+		for next := 0; next < len(members); next++ {
+			for _, neighbour := range tree.RadiusSearch(pointCloud.points[members[next]], tolerance) {
+				if !processed[neighbour] {
+					processed[neighbour] = true
+					members = append(members, neighbour)
+				}
+			}
+		}
+
+		// discard clusters out of the size range
+		if len(members) < minSize || (maxSize > 0 && len(members) > maxSize) {
+			continue
+		}
+		points := make([]Point3D, len(members))
+		for j, index := range members {
+			points[j] = pointCloud.points[index]
+		}
+		clusters = append(clusters, NewCluster(points))
+	}
+
+	// largest clusters first
+	sort.SliceStable(clusters, func(i, j int) bool {
+		return clusters[i].Size > clusters[j].Size
+	})
+
+	return clusters
+}
+
+// creates a cluster from the given points and computes its summary
+func NewCluster(points []Point3D) Cluster {
+	cluster := Cluster{Points: points, Size: len(points)}
+	if len(points) == 0 {
+		return cluster
+	}
+
+	// axis-aligned bounding box
+	cluster.Min, cluster.Max = points[0], points[0]
+	for _, point := range points {
+		cluster.Min = Point3D{math.Min(cluster.Min.X, point.X), math.Min(cluster.Min.Y, point.Y), math.Min(cluster.Min.Z, point.Z)}
+		cluster.Max = Point3D{math.Max(cluster.Max.X, point.X), math.Max(cluster.Max.Y, point.Y), math.Max(cluster.Max.Z, point.Z)}
+	}
+
+	// oriented bounding box along the principal axes
+	centroid, _, axes := getPrincipalAxes(points)
+	cluster.Centroid = centroid
+	cluster.Box = getOrientedBox(points, centroid, axes)
+
+	return cluster
+}
+
+// computes the box enclosing the points with given axes
+func getOrientedBox(points []Point3D, origin Point3D, axes [3]Point3D) OrientedBox {
+	low := [3]float64{math.Inf(1), math.Inf(1), math.Inf(1)}
+	high := [3]float64{math.Inf(-1), math.Inf(-1), math.Inf(-1)}
+	for _, point := range points {
+		d := point.Sub(origin)
+		for i, axis := range axes {
+			t := d.Dot(axis)
+			low[i] = math.Min(low[i], t)
+			high[i] = math.Max(high[i], t)
+		}
+	}
+	box := OrientedBox{Center: origin, Axes: axes}
+	for i, axis := range axes {
+		box.Center = box.Center.Add(axis.Scale((low[i] + high[i]) / 2))
+		box.Extents[i] = high[i] - low[i]
+	}
+	return box
+}
+
+// string representation of a Cluster
+func (c Cluster) String() string {
+	return fmt.Sprintf("%d points, centroid (%v), bounds (%v) - (%v), oriented box extents %.3f x %.3f x %.3f",
+		c.Size, c.Centroid, c.Min, c.Max, c.Box.Extents[2], c.Box.Extents[1], c.Box.Extents[0])
+}
@@ -0,0 +1,149 @@
+package code
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// number of points (with their normals) needed to define a cylinder
+const CYLINDER_SAMPLE_SIZE int = 2
+
+// Cylinder3D represents a cylinder
+type Cylinder3D struct {
+	// point on the axis, the bottom end of the cylinder once bounded to its supporting points
+	Point Point3D
+	// unit direction of the axis
+	Direction Point3D
+	Radius    float64
+	// length of the cylinder along the axis (0 until bounded to its supporting points)
+	Height float64
+}
+
+// computes the cylinder whose surface passes through 2 points with given unit normals
+// the axis is perpendicular to both normals and crosses the normal lines of the points
+// returns an error if the normals are parallel
+func GetCylinder(p1, n1, p2, n2 Point3D) (Cylinder3D, error) {
+	// direction of the axis
+	direction := n1.Cross(n2)
+	if direction.Norm() < 1e-6 {
+		return Cylinder3D{}, errors.New("normals are parallel")
+	}
+	direction = direction.Normalize()
+
+	// closest points of the lines p1 + t n1 and p2 + s n2
+	w := p1.Sub(p2)
+	b := n1.Dot(n2)
+	d := n1.Dot(w)
+	e := n2.Dot(w)
+	denominator := 1 - b*b
+	t := (b*e - d) / denominator
+	s := (e - b*d) / denominator
+	point := p1.Add(n1.Scale(t)).Add(p2.Add(n2.Scale(s))).Scale(0.5)
+
+	cylinder := Cylinder3D{Point: point, Direction: direction}
+	cylinder.Radius = cylinder.GetAxisDistance(&p1)
+	return cylinder, nil
+}
+
+// calculate distance of a point to the axis of the cylinder
+func (c *Cylinder3D) GetAxisDistance(point *Point3D) float64 {
+	v := (*point).Sub(c.Point)
+	return v.Sub(c.Direction.Scale(v.Dot(c.Direction))).Norm()
+}
+
+// calculate distance of a point to the surface of the cylinder
+func (c *Cylinder3D) GetDistance(point *Point3D) float64 {
+	return math.Abs(c.GetAxisDistance(point) - c.Radius)
+}
+
+// bounds the cylinder to the extent of the points along its axis
+func (c *Cylinder3D) SetExtent(points []Point3D) {
+	if len(points) == 0 {
+		return
+	}
+	low, high := math.Inf(1), math.Inf(-1)
+	for _, point := range points {
+		t := point.Sub(c.Point).Dot(c.Direction)
+		low = math.Min(low, t)
+		high = math.Max(high, t)
+	}
+	c.Point = c.Point.Add(c.Direction.Scale(low))
+	c.Height = high - low
+}
+
+// string representation of a Cylinder3D
+func (c Cylinder3D) String() string {
+	return fmt.Sprintf("axis point=(%v), direction=(%v), radius=%f, height=%f", c.Point, c.Direction, c.Radius, c.Height)
+}
+
+// CylinderModel is the Model of cylinders, fitted to samples of 2 points of a point cloud with their normals
+type CylinderModel struct {
+	// points of the cloud and spatial index used to estimate the normals of sampled points
+	points []Point3D
+	tree   *KDTree
+	// number of neighbours used to estimate normals
+	neighbours int
+	// radius limits (maxRadius 0 means no upper limit)
+	minRadius float64
+	maxRadius float64
+	// unit axis direction constraint (zero if none) and cosine of the maximum angle with it
+	axis      Point3D
+	minCosine float64
+}
+
+// creates the model of cylinders of the point cloud
+// normals of the sampled points are estimated from their k nearest neighbours in the point cloud
+// cylinders with radius out of [minRadius, maxRadius] are rejected (maxRadius 0 means no upper limit)
+// if axis is not zero, cylinders whose axis makes an angle of more than maxAxisAngle degrees with it are rejected
+func NewCylinderModel(pointCloud PointCloud, k int, minRadius, maxRadius float64, axis Point3D, maxAxisAngle float64) CylinderModel {
+	if k <= 0 {
+		k = DEFAULT_NORMAL_NEIGHBOURS
+	}
+	return CylinderModel{
+		points:     pointCloud.points,
+		tree:       NewKDTree(pointCloud.points),
+		neighbours: k,
+		minRadius:  minRadius,
+		maxRadius:  maxRadius,
+		axis:       axis.Normalize(),
+		minCosine:  math.Cos(maxAxisAngle * math.Pi / 180),
+	}
+}
+
+// estimates the normal of a point from its neighbourhood
+func (m CylinderModel) normalAt(point Point3D) Point3D {
+	neighbourhood := []Point3D{}
+	for _, index := range m.tree.KNearest(point, m.neighbours) {
+		neighbourhood = append(neighbourhood, m.points[index])
+	}
+	normal, _ := getNormalAndCurvature(neighbourhood)
+	return normal
+}
+
+func (m CylinderModel) SampleSize() int {
+	return CYLINDER_SAMPLE_SIZE
+}
+
+func (m CylinderModel) IsDegenerate(sample []Point3D) bool {
+	return sample[0] == sample[1]
+}
+
+func (m CylinderModel) Fit(sample []Point3D) (Cylinder3D, error) {
+	cylinder, err := GetCylinder(sample[0], m.normalAt(sample[0]), sample[1], m.normalAt(sample[1]))
+	if err != nil {
+		return cylinder, err
+	}
+	if cylinder.Radius < m.minRadius || (m.maxRadius > 0 && cylinder.Radius > m.maxRadius) {
+		return cylinder, errors.New("cylinder radius out of range")
+	}
+	// the axis direction has no sign
+	if m.axis.Norm() > 0 && math.Abs(m.axis.Dot(cylinder.Direction)) < m.minCosine {
+		return cylinder, errors.New("cylinder axis out of orientation range")
+	}
+	return cylinder, nil
+}
+
+func (m CylinderModel) Residual(cylinder Cylinder3D, point *Point3D) float64 {
+	return cylinder.GetDistance(point)
+}
@@ -0,0 +1,235 @@
+package code
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+)
+
+// name of the point attribute holding the heights above a plane
+const DEFAULT_HEIGHT_ATTRIBUTE = "height"
+
+// value of the empty cells of the ASCII grids
+const DEM_NODATA = -9999
+
+// statistics of the heights of the points of a grid cell
+const (
+	DEM_STAT_MIN  = "min"
+	DEM_STAT_MAX  = "max"
+	DEM_STAT_MEAN = "mean"
+)
+
+// image formats of the elevation grids
+const (
+	DEM_IMAGE_PGM = "pgm"
+	DEM_IMAGE_PNG = "png"
+)
+
+// signed distances of the points to a plane, positive on the side of its normal
+func (pointCloud *PointCloud) GetHeights(plane Plane3D) []float64 {
+	normal, h := plane.getHessianForm()
+	heights := make([]float64, len(pointCloud.points))
+	for i := range pointCloud.points {
+		heights[i] = normal.Dot(pointCloud.points[i]) - h
+	}
+	return heights
+}
+
+// sets the signed distances of the points to a plane as the attribute of given name (empty uses
+// DEFAULT_HEIGHT_ATTRIBUTE)
+// returns the heights
+func (pointCloud *PointCloud) SetHeightAttribute(plane Plane3D, name string) []float64 {
+	if name == "" {
+		name = DEFAULT_HEIGHT_ATTRIBUTE
+	}
+	heights := pointCloud.GetHeights(plane)
+	pointCloud.SetAttribute(name, heights)
+	return heights
+}
+
+// ElevationGrid is a raster of the heights of points above a plane, whose cells are squares of the plane frame
+type ElevationGrid struct {
+	// frame of the plane, whose normal points up
+	Frame PlaneFrame
+	// size of the cells
+	CellSize float64
+	// 2D coordinates of the lower left corner of the grid in the frame
+	MinX float64
+	MinY float64
+	// number of cells along the U axis and the V axis of the frame
+	Columns int
+	Rows    int
+	// statistics of the heights of each cell, row by row from the top row (largest V), NaN for empty cells
+	Min  []float64
+	Max  []float64
+	Mean []float64
+	// number of points of each cell
+	Counts []int
+}
+
+// rasterizes the heights of the points above a plane onto a grid of given cell size in the frame of the plane
+// returns an error if the cell size is not positive or the point cloud is empty
+func GetElevationGrid(pointCloud *PointCloud, plane Plane3D, cellSize float64) (ElevationGrid, error) {
+	grid := ElevationGrid{Frame: plane.GetFrame(), CellSize: cellSize}
+	if cellSize <= 0 {
+		return grid, errors.New("cell size must be positive")
+	}
+	if len(pointCloud.points) == 0 {
+		return grid, errors.New("no points to rasterize")
+	}
+
+	heights := pointCloud.GetHeights(plane)
+	projected := grid.Frame.ToPlane2D(pointCloud.points)
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, point := range projected {
+		minX, minY = math.Min(minX, point.X), math.Min(minY, point.Y)
+		maxX, maxY = math.Max(maxX, point.X), math.Max(maxY, point.Y)
+	}
+	grid.MinX, grid.MinY = minX, minY
+	grid.Columns = int(math.Floor((maxX-minX)/cellSize)) + 1
+	grid.Rows = int(math.Floor((maxY-minY)/cellSize)) + 1
+
+	size := grid.Columns * grid.Rows
+	grid.Min, grid.Max, grid.Mean = make([]float64, size), make([]float64, size), make([]float64, size)
+	grid.Counts = make([]int, size)
+	for i := 0; i < size; i++ {
+		grid.Min[i], grid.Max[i] = math.Inf(1), math.Inf(-1)
+	}
+	for i, point := range projected {
+		column := int((point.X - minX) / cellSize)
+		row := grid.Rows - 1 - int((point.Y-minY)/cellSize)
+		cell := row*grid.Columns + column
+		grid.Min[cell] = math.Min(grid.Min[cell], heights[i])
+		grid.Max[cell] = math.Max(grid.Max[cell], heights[i])
+		grid.Mean[cell] += heights[i]
+		grid.Counts[cell]++
+	}
+	for i := 0; i < size; i++ {
+		if grid.Counts[i] == 0 {
+			grid.Min[i], grid.Max[i], grid.Mean[i] = math.NaN(), math.NaN(), math.NaN()
+		} else {
+			grid.Mean[i] /= float64(grid.Counts[i])
+		}
+	}
+	return grid, nil
+}
+
+// returns the cells of a statistic of the heights, DEM_STAT_MIN, DEM_STAT_MAX or DEM_STAT_MEAN
+func (grid *ElevationGrid) Layer(stat string) ([]float64, error) {
+	switch stat {
+	case DEM_STAT_MIN:
+		return grid.Min, nil
+	case DEM_STAT_MAX:
+		return grid.Max, nil
+	case DEM_STAT_MEAN:
+		return grid.Mean, nil
+	}
+	return nil, fmt.Errorf("unknown height statistic: %s", stat)
+}
+
+// returns the 3D point of the lower left corner of the grid, on the plane
+func (grid *ElevationGrid) Origin() Point3D {
+	return grid.Frame.FromPlane(grid.MinX, grid.MinY)
+}
+
+// range of the heights of the non empty cells of a layer
+func getLayerRange(values []float64) (float64, float64) {
+	low, high := math.Inf(1), math.Inf(-1)
+	for _, value := range values {
+		if !math.IsNaN(value) {
+			low, high = math.Min(low, value), math.Max(high, value)
+		}
+	}
+	return low, high
+}
+
+// method to save a layer of the grid to an ESRI ASCII grid file, whose coordinates are those of the plane frame
+func SaveASCIIGrid(filename string, grid ElevationGrid, values []float64) error {
+	if filename == "" {
+		return errors.New("no filename provided")
+	}
+
+	fmt.Println("Saving file: " + filename)
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return errors.New("could not open file")
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	fmt.Fprintf(writer, "ncols %d\nnrows %d\n", grid.Columns, grid.Rows)
+	fmt.Fprintf(writer, "xllcorner %f\nyllcorner %f\n", grid.MinX, grid.MinY)
+	fmt.Fprintf(writer, "cellsize %f\nNODATA_value %d\n", grid.CellSize, DEM_NODATA)
+	for row := 0; row < grid.Rows; row++ {
+		for column := 0; column < grid.Columns; column++ {
+			if column > 0 {
+				writer.WriteString(" ")
+			}
+			value := values[row*grid.Columns+column]
+			if math.IsNaN(value) {
+				fmt.Fprintf(writer, "%d", DEM_NODATA)
+			} else {
+				fmt.Fprintf(writer, "%f", value)
+			}
+		}
+		writer.WriteString("\n")
+	}
+	return writer.Flush()
+}
+
+// method to save a layer of the grid to a 16-bit grayscale image of given format, DEM_IMAGE_PGM or DEM_IMAGE_PNG
+// the heights are scaled from 1 for the lowest to 65535 for the highest, empty cells being 0
+// returns the heights of the lowest and the highest gray levels
+func SaveGridImage(filename string, grid ElevationGrid, values []float64, format string) (float64, float64, error) {
+	if filename == "" {
+		return 0, 0, errors.New("no filename provided")
+	}
+	if format != DEM_IMAGE_PGM && format != DEM_IMAGE_PNG {
+		return 0, 0, fmt.Errorf("unknown image format: %s", format)
+	}
+
+	low, high := getLayerRange(values)
+	levels := make([]uint16, len(values))
+	for i, value := range values {
+		if math.IsNaN(value) {
+			continue
+		}
+		if high > low {
+			levels[i] = uint16(1 + math.Round((value-low)/(high-low)*65534))
+		} else {
+			levels[i] = 65535
+		}
+	}
+
+	fmt.Println("Saving file: " + filename)
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return low, high, errors.New("could not open file")
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	if format == DEM_IMAGE_PGM {
+		// binary PGM, whose 16-bit samples are big endian
+		fmt.Fprintf(writer, "P5\n%d %d\n65535\n", grid.Columns, grid.Rows)
+		err = binary.Write(writer, binary.BigEndian, levels)
+	} else {
+		img := image.NewGray16(image.Rect(0, 0, grid.Columns, grid.Rows))
+		for i, level := range levels {
+			img.SetGray16(i%grid.Columns, i/grid.Columns, color.Gray16{Y: level})
+		}
+		err = png.Encode(writer, img)
+	}
+	if err != nil {
+		return low, high, err
+	}
+	return low, high, writer.Flush()
+}
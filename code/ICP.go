@@ -0,0 +1,524 @@
+package code
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"runtime"
+	"sync"
+)
+
+// ICP error metrics
+const (
+	// distance between corresponding points
+	ICP_POINT_TO_POINT = "point"
+	// distance from the source points to the tangent planes of their corresponding target points
+	ICP_POINT_TO_PLANE = "plane"
+)
+
+// default maximum number of ICP iterations
+const DEFAULT_ICP_ITERATIONS int = 50
+
+// default change of the RMS error between 2 ICP iterations below which ICP has converged
+const DEFAULT_ICP_TOLERANCE float64 = 1e-6
+
+// default number of source points matched at each ICP iteration, drawn at random from the source point cloud
+const DEFAULT_ICP_SAMPLES int = 10000
+
+// default number of dominant planes matched by the plane-based initialization
+const DEFAULT_REGISTRATION_PLANES int = 4
+
+// parameters of the ICP registration
+type ICPOptions struct {
+	// error metric, ICP_POINT_TO_POINT or ICP_POINT_TO_PLANE (empty uses ICP_POINT_TO_POINT)
+	Method string
+	// maximum number of iterations (0 uses DEFAULT_ICP_ITERATIONS)
+	MaxIterations int
+	// change of the RMS error below which ICP has converged (0 uses DEFAULT_ICP_TOLERANCE)
+	Tolerance float64
+	// maximum distance between corresponding points (0 means no limit)
+	MaxDistance float64
+	// number of source points matched at each iteration (0 uses DEFAULT_ICP_SAMPLES)
+	Samples int
+	// number of neighbours used to estimate the normals of the target points (0 uses DEFAULT_NORMAL_NEIGHBOURS)
+	NormalNeighbours int
+	// transform the registration starts from (nil starts from the identity)
+	Initial *RigidTransform
+}
+
+// ICPResult is the outcome of an ICP registration
+type ICPResult struct {
+	// transform mapping the source point cloud onto the target point cloud
+	Transform  RigidTransform `json:"transform"`
+	Iterations int            `json:"iterations"`
+	Converged  bool           `json:"converged"`
+	// root mean square distance between corresponding points before the first iteration and for the transform
+	InitialRMS float64 `json:"initial_rms"`
+	RMS        float64 `json:"rms"`
+	// number of corresponding points for the transform, and their fraction of the matched source points
+	Correspondences int     `json:"correspondences"`
+	Fitness         float64 `json:"fitness"`
+}
+
+// options of the registration of 2 point clouds
+type RegistrationOptions struct {
+	ICP ICPOptions
+	// initialize ICP by matching the dominant planes of the point clouds
+	InitPlanes bool
+	// number of dominant planes matched (0 uses DEFAULT_REGISTRATION_PLANES)
+	NumOfPlanes int
+	// eps of the detection of the dominant planes (0 uses 3 times the noise estimated from the point clouds)
+	PlaneEps float64
+}
+
+// RegistrationReport describes the registration of 2 point clouds
+type RegistrationReport struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Method string `json:"method"`
+	// transform found by matching the dominant planes, if any
+	PlaneTransform *RigidTransform `json:"plane_transform,omitempty"`
+	ICPResult
+	RegisteredFile string `json:"registered_file"`
+	TransformFile  string `json:"transform_file"`
+}
+
+// pair of a source point, moved by the current transform, and its closest target point
+type icpCorrespondence struct {
+	source Point3D
+	target int
+}
+
+// registers the source point cloud onto the target point cloud with the Iterative Closest Point algorithm
+// at each iteration, a sample of the source points moved by the current transform are paired with their closest
+// target points within the maximum distance, and the transform is updated to minimize the point to point distances
+// (closed form, Kabsch) or the point to plane distances (linearized rotation), until the RMS distance between the
+// pairs stops decreasing by more than the tolerance, or increases
+// returns the transform with the lowest RMS distance seen
+// returns an error if the point clouds have too few corresponding points
+func ICP(source, target PointCloud, options ICPOptions) (ICPResult, error) {
+	// use defaults for unset parameters
+	if options.Method == "" {
+		options.Method = ICP_POINT_TO_POINT
+	}
+	if options.Method != ICP_POINT_TO_POINT && options.Method != ICP_POINT_TO_PLANE {
+		return ICPResult{}, fmt.Errorf("unknown ICP method: %s", options.Method)
+	}
+	if options.MaxIterations <= 0 {
+		options.MaxIterations = DEFAULT_ICP_ITERATIONS
+	}
+	if options.Tolerance <= 0 {
+		options.Tolerance = DEFAULT_ICP_TOLERANCE
+	}
+	if options.Samples <= 0 {
+		options.Samples = DEFAULT_ICP_SAMPLES
+	}
+	if len(source.points) == 0 || len(target.points) == 0 {
+		return ICPResult{}, errors.New("empty point cloud")
+	}
+
+	// sample of the source points
+	samples := source.points
+	if len(samples) > options.Samples {
+		samples = make([]Point3D, options.Samples)
+		for i, index := range rand.Perm(len(source.points))[:options.Samples] {
+			samples[i] = source.points[index]
+		}
+	}
+	tree := NewKDTree(target.points)
+	var normals []Point3D
+	if options.Method == ICP_POINT_TO_PLANE {
+		normals, _ = target.EstimateNormals(options.NormalNeighbours)
+	}
+
+	// the transform of the current iteration, the result keeping the transform with the lowest RMS distance
+	transform := IdentityTransform()
+	if options.Initial != nil {
+		transform = *options.Initial
+	}
+	result := ICPResult{Transform: transform, RMS: math.Inf(1)}
+	previousRMS := math.Inf(1)
+	for iteration := 0; ; iteration++ {
+		correspondences := getCorrespondences(samples, transform, target.points, tree, options.MaxDistance)
+		minCorrespondences := 3
+		if options.Method == ICP_POINT_TO_PLANE {
+			minCorrespondences = 6
+		}
+		if len(correspondences) < minCorrespondences {
+			return result, errors.New("not enough corresponding points")
+		}
+		rms := 0.0
+		for _, pair := range correspondences {
+			d := pair.source.Sub(target.points[pair.target])
+			rms += d.Dot(d)
+		}
+		rms = math.Sqrt(rms / float64(len(correspondences)))
+		if iteration == 0 {
+			result.InitialRMS = rms
+		}
+		result.Iterations = iteration
+		if rms < result.RMS {
+			result.Transform, result.RMS, result.Correspondences = transform, rms, len(correspondences)
+			result.Fitness = float64(len(correspondences)) / float64(len(samples))
+		}
+		// ICP has converged once the RMS distance decreases by less than the tolerance, and stops without converging
+		// if the last step increased it
+		if rms > previousRMS {
+			break
+		}
+		if previousRMS-rms < options.Tolerance {
+			result.Converged = true
+			break
+		}
+		if iteration == options.MaxIterations {
+			break
+		}
+		previousRMS = rms
+
+		// update the transform with the step aligning the moved source points with their target points
+		var step RigidTransform
+		var err error
+		if options.Method == ICP_POINT_TO_PLANE {
+			step, err = getPointToPlaneStep(correspondences, target.points, normals)
+		} else {
+			step = getPointToPointStep(correspondences, target.points)
+		}
+		if err != nil {
+			return result, err
+		}
+		transform = transform.Then(step)
+	}
+
+	return result, nil
+}
+
+// pairs the source points moved by the transform with their closest target points within maxDistance (0 means no
+// limit), with a worker per CPU each pairing a chunk of the points
+func getCorrespondences(points []Point3D, transform RigidTransform, targets []Point3D, tree *KDTree, maxDistance float64) []icpCorrespondence {
+	pairs := make([]icpCorrespondence, len(points))
+	found := make([]bool, len(points))
+	workers := runtime.NumCPU()
+	chunkSize := (len(points) + workers - 1) / workers
+	var wg sync.WaitGroup
+	for start := 0; start < len(points); start += chunkSize {
+		end := start + chunkSize
+		if end > len(points) {
+			end = len(points)
+		}
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			for i := start; i < end; i++ {
+				moved := transform.Apply(points[i])
+				nearest := tree.KNearest(moved, 1)
+				if len(nearest) == 0 || (maxDistance > 0 && moved.Sub(targets[nearest[0]]).Norm() > maxDistance) {
+					continue
+				}
+				pairs[i], found[i] = icpCorrespondence{moved, nearest[0]}, true
+			}
+		}(start, end)
+	}
+	wg.Wait()
+
+	correspondences := []icpCorrespondence{}
+	for i, pair := range pairs {
+		if found[i] {
+			correspondences = append(correspondences, pair)
+		}
+	}
+	return correspondences
+}
+
+// computes the rigid transform minimizing the squared distances between the corresponding points
+func getPointToPointStep(correspondences []icpCorrespondence, targets []Point3D) RigidTransform {
+	from := make([]Point3D, len(correspondences))
+	to := make([]Point3D, len(correspondences))
+	for i, pair := range correspondences {
+		from[i], to[i] = pair.source, targets[pair.target]
+	}
+	sourceCentroid, targetCentroid := GetCentroid(from), GetCentroid(to)
+
+	// cross covariance of the centered points
+	covariance := Matrix3{}
+	for i := range from {
+		p, q := from[i].Sub(sourceCentroid), to[i].Sub(targetCentroid)
+		pv, qv := [3]float64{p.X, p.Y, p.Z}, [3]float64{q.X, q.Y, q.Z}
+		for a := 0; a < 3; a++ {
+			for b := 0; b < 3; b++ {
+				covariance[a][b] += pv[a] * qv[b]
+			}
+		}
+	}
+
+	rotation := getKabschRotation(covariance)
+	return RigidTransform{Rotation: rotation, Translation: targetCentroid.Sub(rotation.MulVector(sourceCentroid))}
+}
+
+// computes the rotation R maximizing the sum of q . R p over the pairs (p, q) of vectors whose cross covariance,
+// the sum of p q^T, is given (Kabsch)
+// with H = U S V^T, R = V U^T, V being obtained from the eigenvectors of H^T H and U = H V S^-1; the third axes of
+// U and V are the cross products of the first 2, which keeps R a rotation and handles planar sets of vectors
+func getKabschRotation(covariance Matrix3) Matrix3 {
+	_, vectors := eigenSymmetric3(covariance.Transpose().Mul(covariance))
+	// right singular vectors by decreasing singular value
+	v1, v2 := vectors[2], vectors[1]
+	u1 := covariance.MulVector(v1).Normalize()
+	u2 := covariance.MulVector(v2)
+	u2 = u2.Sub(u1.Scale(u2.Dot(u1))).Normalize()
+	if u1.Norm() == 0 || u2.Norm() == 0 {
+		return Identity3()
+	}
+	v3, u3 := v1.Cross(v2), u1.Cross(u2)
+
+	rotation := Matrix3{}
+	vs, us := [3]Point3D{v1, v2, v3}, [3]Point3D{u1, u2, u3}
+	for i := 0; i < 3; i++ {
+		vv, uv := [3]float64{vs[i].X, vs[i].Y, vs[i].Z}, [3]float64{us[i].X, us[i].Y, us[i].Z}
+		for a := 0; a < 3; a++ {
+			for b := 0; b < 3; b++ {
+				rotation[a][b] += vv[a] * uv[b]
+			}
+		}
+	}
+	return rotation
+}
+
+// computes the rigid transform minimizing the squared distances from the source points to the tangent planes of
+// their corresponding target points, with the rotation linearized as x + w x x for a small rotation vector w
+// returns an error if the tangent planes do not constrain the transform
+func getPointToPlaneStep(correspondences []icpCorrespondence, targets []Point3D, normals []Point3D) (RigidTransform, error) {
+	rows := make([][]float64, len(correspondences))
+	rhs := make([]float64, len(correspondences))
+	for i, pair := range correspondences {
+		n := normals[pair.target]
+		c := pair.source.Cross(n)
+		rows[i] = []float64{c.X, c.Y, c.Z, n.X, n.Y, n.Z}
+		rhs[i] = -pair.source.Sub(targets[pair.target]).Dot(n)
+	}
+	x, err := solveLeastSquares(rows, rhs)
+	if err != nil {
+		return RigidTransform{}, errors.New("point to plane ICP is degenerate")
+	}
+	w := Point3D{x[0], x[1], x[2]}
+	return RigidTransform{Rotation: AxisAngleRotation(w, w.Norm()), Translation: Point3D{x[3], x[4], x[5]}}, nil
+}
+
+// computes a coarse transform mapping the source dominant planes onto the target dominant planes
+// the normals of each point cloud are oriented towards its centroid, so that the normals of the planes of a room
+// point inside whatever the station; the rotation is the one aligning a pair of non parallel source normals with a
+// pair of target normals at the same angle (within maxAngle degrees, 0 uses DEFAULT_ORIENTATION_ANGLE) that brings
+// the most supporting points of the source planes within maxAngle of a target normal, and the translation fits the
+// offsets of the matched planes, the directions they leave free being fitted to the centroids
+// returns an error if no pair of non parallel planes matches
+func AlignPlanes(source, target []Plane3DwSupport, sourceCentroid, targetCentroid Point3D, maxAngle float64) (RigidTransform, error) {
+	if maxAngle <= 0 {
+		maxAngle = DEFAULT_ORIENTATION_ANGLE
+	}
+	minCosine := math.Cos(maxAngle * math.Pi / 180)
+	sourceNormals, sourceOffsets := getInwardPlanes(source, sourceCentroid)
+	targetNormals, targetOffsets := getInwardPlanes(target, targetCentroid)
+
+	// target plane matching each source plane under a rotation, -1 if none
+	match := func(rotation Matrix3) ([]int, int) {
+		matches, support := make([]int, len(source)), 0
+		for i := range source {
+			matches[i] = -1
+			rotated, best := rotation.MulVector(sourceNormals[i]), minCosine
+			for j := range target {
+				if cosine := rotated.Dot(targetNormals[j]); cosine >= best {
+					matches[i], best = j, cosine
+				}
+			}
+			if matches[i] >= 0 {
+				support += source[i].SupportSize
+			}
+		}
+		return matches, support
+	}
+
+	// rotations aligning pairs of source normals with pairs of target normals
+	var bestMatches []int
+	bestRotation, bestSupport := Identity3(), 0
+	for i1 := range source {
+		for i2 := range source {
+			cosine := sourceNormals[i1].Dot(sourceNormals[i2])
+			if i1 == i2 || math.Abs(cosine) > minCosine {
+				continue
+			}
+			for j1 := range target {
+				for j2 := range target {
+					if j1 == j2 || math.Abs(targetNormals[j1].Dot(targetNormals[j2])-cosine) > 1-minCosine {
+						continue
+					}
+					covariance := Matrix3{}
+					pairs := [][2]Point3D{
+						{sourceNormals[i1], targetNormals[j1]},
+						{sourceNormals[i2], targetNormals[j2]},
+						{sourceNormals[i1].Cross(sourceNormals[i2]), targetNormals[j1].Cross(targetNormals[j2])},
+					}
+					for _, pair := range pairs {
+						p, q := [3]float64{pair[0].X, pair[0].Y, pair[0].Z}, [3]float64{pair[1].X, pair[1].Y, pair[1].Z}
+						for a := 0; a < 3; a++ {
+							for b := 0; b < 3; b++ {
+								covariance[a][b] += p[a] * q[b]
+							}
+						}
+					}
+					rotation := getKabschRotation(covariance)
+					if matches, support := match(rotation); support > bestSupport {
+						bestMatches, bestRotation, bestSupport = matches, rotation, support
+					}
+				}
+			}
+		}
+	}
+	if bestSupport == 0 {
+		return RigidTransform{}, errors.New("no matching pair of non parallel planes")
+	}
+
+	// translation t fitting the offsets of the matched planes, (R n) . t = h' - h, regularized towards the translation
+	// of the centroids
+	normalMatrix := make([][]float64, 3)
+	rhs := make([]float64, 3)
+	for a := range normalMatrix {
+		normalMatrix[a] = make([]float64, 3)
+	}
+	for i, j := range bestMatches {
+		if j < 0 {
+			continue
+		}
+		m := bestRotation.MulVector(sourceNormals[i])
+		mv := [3]float64{m.X, m.Y, m.Z}
+		for a := 0; a < 3; a++ {
+			rhs[a] += mv[a] * (targetOffsets[j] - sourceOffsets[i])
+			for b := 0; b < 3; b++ {
+				normalMatrix[a][b] += mv[a] * mv[b]
+			}
+		}
+	}
+	centroidTranslation := targetCentroid.Sub(bestRotation.MulVector(sourceCentroid))
+	prior := [3]float64{centroidTranslation.X, centroidTranslation.Y, centroidTranslation.Z}
+	regularization := 1e-3 * (normalMatrix[0][0] + normalMatrix[1][1] + normalMatrix[2][2])
+	for a := 0; a < 3; a++ {
+		normalMatrix[a][a] += regularization
+		rhs[a] += regularization * prior[a]
+	}
+	t, err := solveLinear(normalMatrix, rhs)
+	if err != nil {
+		return RigidTransform{}, err
+	}
+	return RigidTransform{Rotation: bestRotation, Translation: Point3D{t[0], t[1], t[2]}}, nil
+}
+
+// returns the unit normals of the planes oriented towards a point, and the offsets h such that normal . x = h
+func getInwardPlanes(planes []Plane3DwSupport, inside Point3D) ([]Point3D, []float64) {
+	normals := make([]Point3D, len(planes))
+	offsets := make([]float64, len(planes))
+	for i := range planes {
+		normals[i], offsets[i] = planes[i].getHessianForm()
+		if normals[i].Dot(inside) < offsets[i] {
+			normals[i], offsets[i] = normals[i].Scale(-1), -offsets[i]
+		}
+	}
+	return normals, offsets
+}
+
+// computes a coarse transform mapping the source point cloud onto the target point cloud from their numOfPlanes
+// dominant planes, detected with given number of RANSAC iterations and eps and refitted to their supporting points
+func AlignByPlanes(source, target PointCloud, numOfIterations int, eps float64, numOfPlanes int) (RigidTransform, error) {
+	if numOfPlanes <= 0 {
+		numOfPlanes = DEFAULT_REGISTRATION_PLANES
+	}
+	sourcePlanes, _ := getDominantPlanes(numOfIterations, source, eps, PlaneModel{}, EngineOptions{Refit: true}, numOfPlanes)
+	targetPlanes, _ := getDominantPlanes(numOfIterations, target, eps, PlaneModel{}, EngineOptions{Refit: true}, numOfPlanes)
+	return AlignPlanes(sourcePlanes, targetPlanes, GetCentroid(source.points), GetCentroid(target.points), 0)
+}
+
+// method to register the point cloud of a source file onto the point cloud of a target file, saving the registered
+// source point cloud, the transform and a report to files
+func Register(sourceFile, targetFile string, confidence, percentageOfPointsOnPlane float64, options ...RegistrationOptions) {
+	fmt.Println("Initiating registration")
+	// use default options if none provided
+	if len(options) == 0 {
+		options = []RegistrationOptions{{}}
+	}
+	if options[0].ICP.Method == "" {
+		options[0].ICP.Method = ICP_POINT_TO_POINT
+	}
+
+	// get the PointClouds
+	source, err := readXYZ(sourceFile)
+	if err != nil {
+		fmt.Println("Unable to get source Point Cloud", err)
+		os.Exit(1)
+	}
+	target, err := readXYZ(targetFile)
+	if err != nil {
+		fmt.Println("Unable to get target Point Cloud", err)
+		os.Exit(1)
+	}
+	fmt.Println("Source Point Cloud size: ", len(source.points))
+	fmt.Println("Target Point Cloud size: ", len(target.points))
+
+	report := RegistrationReport{Source: sourceFile, Target: targetFile, Method: options[0].ICP.Method}
+
+	// coarse alignment of the dominant planes
+	if options[0].InitPlanes {
+		eps := options[0].PlaneEps
+		if eps <= 0 {
+			sigma := math.Max(source.EstimateNoise(0), target.EstimateNoise(0))
+			eps, err = getNoiseEps(sigma, DEFAULT_EPS_SIGMA_FACTOR, 0)
+			if err != nil {
+				fmt.Println("Unable to estimate epsilon", err)
+				os.Exit(1)
+			}
+			fmt.Println("Estimated epsilon: ", eps)
+		}
+		numOfIterations := getNumberOfIterations(confidence, percentageOfPointsOnPlane)
+		initial, err := AlignByPlanes(source, target, numOfIterations, eps, options[0].NumOfPlanes)
+		if err != nil {
+			fmt.Println("Unable to match dominant planes", err)
+			os.Exit(1)
+		}
+		if options[0].ICP.Initial != nil {
+			fmt.Println("Initial transform replaced by the alignment of the dominant planes")
+		}
+		fmt.Println("Dominant planes transform: ", initial.Matrix())
+		options[0].ICP.Initial = &initial
+		report.PlaneTransform = &initial
+	}
+
+	result, err := ICP(source, target, options[0].ICP)
+	if err != nil {
+		fmt.Println("Unable to register point clouds", err)
+		os.Exit(1)
+	}
+	report.ICPResult = result
+	fmt.Printf("ICP %s: %d iterations, converged: %v\n", options[0].ICP.Method, result.Iterations, result.Converged)
+	fmt.Printf("RMS: %f -> %f, fitness: %f\n", result.InitialRMS, result.RMS, result.Fitness)
+	fmt.Println("Registration transform: ", result.Transform.Matrix())
+
+	// save the registered source point cloud, the transform and the report
+	registered := source.Transform(result.Transform)
+	report.RegisteredFile = getOutputFilename(sourceFile, "_registered.xyz")
+	err = saveXYZ(report.RegisteredFile, registered.points)
+	if err != nil {
+		fmt.Println("Unable to save registered point cloud", err)
+		os.Exit(1)
+	}
+	report.TransformFile = getOutputFilename(sourceFile, "_registration.txt")
+	err = SaveTransform(report.TransformFile, result.Transform)
+	if err != nil {
+		fmt.Println("Unable to save registration transform", err)
+		os.Exit(1)
+	}
+	err = saveReport(getOutputFilename(sourceFile, "_registration.json"), report)
+	if err != nil {
+		fmt.Println("Unable to save report", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("Program completed successfully :)")
+}
@@ -0,0 +1,284 @@
+package code
+
+import (
+	"math"
+	"math/bits"
+	"math/rand"
+	"sort"
+)
+
+// default number of plane hypotheses generated by J-linkage
+const DEFAULT_JLINKAGE_HYPOTHESES int = 2000
+
+// default number of points clustered by J-linkage, drawn at random from the point cloud
+const DEFAULT_JLINKAGE_POINTS int = 1000
+
+// default number of nearest neighbours of the first point of a sample among which the other points are drawn
+const DEFAULT_JLINKAGE_NEIGHBOURS int = 20
+
+// points prefer the hypotheses within JLINKAGE_PREFERENCE_FACTOR times eps of them, so that the points of a noisy
+// plane share enough hypotheses to end in the same cluster
+const JLINKAGE_PREFERENCE_FACTOR float64 = 3
+
+// parameters of the J-linkage multi-plane extraction
+type JLinkageOptions struct {
+	// number of plane hypotheses (0 uses DEFAULT_JLINKAGE_HYPOTHESES)
+	Hypotheses int
+	// number of points clustered (0 uses DEFAULT_JLINKAGE_POINTS)
+	Points int
+	// number of neighbours among which the samples are drawn (0 uses DEFAULT_JLINKAGE_NEIGHBOURS)
+	Neighbours int
+}
+
+// set of the hypotheses supported by a point or shared by the points of a cluster, as a bit set
+type preferenceSet []uint64
+
+// Jaccard distance between two preference sets, 1 if they share no hypothesis
+func (a preferenceSet) distance(b preferenceSet) float64 {
+	intersection, union := 0, 0
+	for i := range a {
+		intersection += bits.OnesCount64(a[i] & b[i])
+		union += bits.OnesCount64(a[i] | b[i])
+	}
+	if union == 0 {
+		return 1
+	}
+	return 1 - float64(intersection)/float64(union)
+}
+
+// hypotheses shared by two preference sets
+func (a preferenceSet) intersect(b preferenceSet) preferenceSet {
+	result := make(preferenceSet, len(a))
+	for i := range a {
+		result[i] = a[i] & b[i]
+	}
+	return result
+}
+
+// method to retrieve given number of dominant planes from the point cloud with J-linkage
+// instead of extracting the planes one after the other, plane hypotheses are generated once, and a sample of points
+// is clustered by the hypotheses they support, so that no plane depends on the planes extracted before it
+// each point of the cloud is then assigned to the closest plane fitted to a cluster, points farther than eps of all
+// planes being outliers
+// returns the planes with the most points, and the point cloud without the points belonging to them
+func getJLinkagePlanes(pointCloud PointCloud, eps float64, options JLinkageOptions, numOfDominantPlanes ...int) ([]Plane3DwSupport, PointCloud) {
+	// if the number of dominant planes is not specified, set it to the default value
+	if len(numOfDominantPlanes) == 0 {
+		numOfDominantPlanes = []int{DEFAULT_NUM_OF_DOMINANT_PLANES}
+	}
+	// use defaults for unset parameters
+	if options.Points <= 0 {
+		options.Points = DEFAULT_JLINKAGE_POINTS
+	}
+
+	// number of points needed to fit a plane
+	minSize := PlaneModel{}.SampleSize()
+
+	// cluster a random sample of the points
+	sample := []Point3D{}
+	for _, index := range rand.Perm(len(pointCloud.points)) {
+		if len(sample) == options.Points {
+			break
+		}
+		sample = append(sample, pointCloud.points[index])
+	}
+	planes := []Plane3D{}
+	for _, cluster := range getJLinkageClusters(sample, JLINKAGE_PREFERENCE_FACTOR*eps, options) {
+		if len(cluster) < minSize {
+			continue
+		}
+		points := make([]Point3D, len(cluster))
+		for i, index := range cluster {
+			points[i] = sample[index]
+		}
+		planes = append(planes, FitPlane(points))
+	}
+
+	// assign the points to the planes, refit the planes to their points and assign the points again
+	labels := assignToPlanes(pointCloud.points, planes, eps)
+	for i := range planes {
+		points := []Point3D{}
+		for j, label := range labels {
+			if label == i {
+				points = append(points, pointCloud.points[j])
+			}
+		}
+		if len(points) >= minSize {
+			planes[i] = FitPlane(points)
+		}
+	}
+	labels = assignToPlanes(pointCloud.points, planes, eps)
+
+	// count the points of each plane and keep the planes with the most points
+	counts := make([]int, len(planes))
+	for _, label := range labels {
+		if label >= 0 {
+			counts[label]++
+		}
+	}
+	order := []int{}
+	for i, count := range counts {
+		if count > 0 {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return counts[order[i]] > counts[order[j]]
+	})
+	if len(order) > numOfDominantPlanes[0] {
+		order = order[:numOfDominantPlanes[0]]
+	}
+
+	// gather the points of the kept planes
+	position := make([]int, len(planes))
+	for i := range position {
+		position[i] = -1
+	}
+	dominantPlanes := make([]Plane3DwSupport, len(order))
+	for i, plane := range order {
+		position[plane] = i
+		dominantPlanes[i] = Plane3DwSupport{Plane3D: planes[plane], SupportSize: counts[plane]}
+	}
+	for j, label := range labels {
+		if label >= 0 && position[label] >= 0 {
+			dominantPlanes[position[label]].SupportingPoints = append(dominantPlanes[position[label]].SupportingPoints, pointCloud.points[j])
+		}
+	}
+
+	// outliers and points of the discarded planes
+	remaining := pointCloud.filter(func(i int) bool {
+		return labels[i] < 0 || position[labels[i]] < 0
+	})
+
+	return dominantPlanes, remaining
+}
+
+// returns the index of the closest plane within eps of each point, -1 if none
+func assignToPlanes(points []Point3D, planes []Plane3D, eps float64) []int {
+	labels := make([]int, len(points))
+	for i := range points {
+		labels[i] = -1
+		closest := math.Inf(1)
+		for j := range planes {
+			if distance := planes[j].GetDistance(&points[i]); distance <= eps && distance < closest {
+				labels[i] = j
+				closest = distance
+			}
+		}
+	}
+	return labels
+}
+
+// clusters the points by the plane hypotheses they support (J-linkage)
+// the hypotheses are fitted to samples of neighbouring and random points, and the clusters with the closest preference sets are
+// merged until no two clusters share a hypothesis
+// returns the indices of the points of each cluster
+func getJLinkageClusters(points []Point3D, eps float64, options JLinkageOptions) [][]int {
+	// use defaults for unset parameters
+	if options.Hypotheses <= 0 {
+		options.Hypotheses = DEFAULT_JLINKAGE_HYPOTHESES
+	}
+	if options.Neighbours <= 0 {
+		options.Neighbours = DEFAULT_JLINKAGE_NEIGHBOURS
+	}
+	if len(points) < 3 {
+		return [][]int{}
+	}
+
+	// generate the hypotheses, half from samples of neighbouring points, which find the small planes,
+	// and half from uniform samples, which span the large planes
+	tree := NewKDTree(points)
+	hypotheses := []Plane3D{}
+	for attempt := 0; len(hypotheses) < options.Hypotheses && attempt < 10*options.Hypotheses; attempt++ {
+		first := rand.Intn(len(points))
+		var p2, p3 Point3D
+		if attempt%2 == 0 {
+			neighbours := tree.KNearest(points[first], options.Neighbours)
+			if len(neighbours) < 3 {
+				continue
+			}
+			others := distinctIndices(len(neighbours), 2, rand.Intn)
+			p2, p3 = points[neighbours[others[0]]], points[neighbours[others[1]]]
+		} else {
+			others := distinctIndices(len(points), 2, rand.Intn)
+			p2, p3 = points[others[0]], points[others[1]]
+		}
+		p1 := points[first]
+		if p1 == p2 || p1 == p3 || isCollinear(p1, p2, p3) {
+			continue
+		}
+		hypotheses = append(hypotheses, GetPlane(p1, p2, p3))
+	}
+
+	// preference set of each point, which starts its own cluster
+	words := (len(hypotheses) + 63) / 64
+	clusters := make([][]int, len(points))
+	preferences := make([]preferenceSet, len(points))
+	for i := range points {
+		clusters[i] = []int{i}
+		preferences[i] = make(preferenceSet, words)
+		for h := range hypotheses {
+			if hypotheses[h].GetDistance(&points[i]) <= eps {
+				preferences[i][h/64] |= 1 << (h % 64)
+			}
+		}
+	}
+
+	// closest cluster of each cluster
+	active := make([]bool, len(points))
+	nearest := make([]int, len(points))
+	nearestDistance := make([]float64, len(points))
+	for i := range active {
+		active[i] = true
+	}
+	updateNearest := func(i int) {
+		nearest[i], nearestDistance[i] = -1, 1
+		for j := range clusters {
+			if j == i || !active[j] {
+				continue
+			}
+			if distance := preferences[i].distance(preferences[j]); distance < nearestDistance[i] {
+				nearest[i], nearestDistance[i] = j, distance
+			}
+		}
+	}
+	for i := range clusters {
+		updateNearest(i)
+	}
+
+	// merge the closest clusters
+	for {
+		i := -1
+		for j := range clusters {
+			if active[j] && nearest[j] >= 0 && (i < 0 || nearestDistance[j] < nearestDistance[i]) {
+				i = j
+			}
+		}
+		if i < 0 {
+			break
+		}
+		j := nearest[i]
+		clusters[i] = append(clusters[i], clusters[j]...)
+		preferences[i] = preferences[i].intersect(preferences[j])
+		active[j] = false
+		updateNearest(i)
+		for k := range clusters {
+			if k == i || !active[k] {
+				continue
+			}
+			if nearest[k] == i || nearest[k] == j {
+				updateNearest(k)
+			} else if distance := preferences[k].distance(preferences[i]); distance < nearestDistance[k] {
+				nearest[k], nearestDistance[k] = i, distance
+			}
+		}
+	}
+
+	result := [][]int{}
+	for i, cluster := range clusters {
+		if active[i] {
+			result = append(result, cluster)
+		}
+	}
+	return result
+}
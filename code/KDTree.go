@@ -0,0 +1,136 @@
+package code
+
+import (
+	"sort"
+)
+
+// KDTree is a k-d tree over a set of 3D points used for neighbourhood queries
+type KDTree struct {
+	points []Point3D
+	root   *kdNode
+}
+
+// node of a KDTree, storing the index of a point and the splitting axis
+type kdNode struct {
+	index int
+	axis  int
+	left  *kdNode
+	right *kdNode
+}
+
+// builds a k-d tree over the given points
+// queries return indices into the points slice
+func NewKDTree(points []Point3D) *KDTree {
+	indices := make([]int, len(points))
+	for i := range indices {
+		indices[i] = i
+	}
+	tree := &KDTree{points: points}
+	tree.root = tree.build(indices, 0)
+	return tree
+}
+
+// recursively build the tree by splitting the points at the median of the current axis
+func (tree *KDTree) build(indices []int, depth int) *kdNode {
+	if len(indices) == 0 {
+		return nil
+	}
+	axis := depth % 3
+	sort.Slice(indices, func(i, j int) bool {
+		return coordinate(tree.points[indices[i]], axis) < coordinate(tree.points[indices[j]], axis)
+	})
+	median := len(indices) / 2
+	return &kdNode{
+		index: indices[median],
+		axis:  axis,
+		left:  tree.build(indices[:median], depth+1),
+		right: tree.build(indices[median+1:], depth+1),
+	}
+}
+
+// returns the indices of all points within radius of the query point
+func (tree *KDTree) RadiusSearch(query Point3D, radius float64) []int {
+	result := []int{}
+	tree.radiusSearch(tree.root, query, radius*radius, radius, &result)
+	return result
+}
+
+func (tree *KDTree) radiusSearch(node *kdNode, query Point3D, radius2, radius float64, result *[]int) {
+	if node == nil {
+		return
+	}
+	point := tree.points[node.index]
+	if d := point.Sub(query); d.Dot(d) <= radius2 {
+		*result = append(*result, node.index)
+	}
+	// distance of the query point to the splitting plane
+	delta := coordinate(query, node.axis) - coordinate(point, node.axis)
+	if delta <= radius {
+		tree.radiusSearch(node.left, query, radius2, radius, result)
+	}
+	if delta >= -radius {
+		tree.radiusSearch(node.right, query, radius2, radius, result)
+	}
+}
+
+// returns the indices of the k points nearest to the query point, nearest first
+func (tree *KDTree) KNearest(query Point3D, k int) []int {
+	if k <= 0 {
+		return []int{}
+	}
+	// candidates sorted by increasing squared distance
+	nearest := make([]kdCandidate, 0, k+1)
+	tree.kNearest(tree.root, query, k, &nearest)
+	result := make([]int, len(nearest))
+	for i, candidate := range nearest {
+		result[i] = candidate.index
+	}
+	return result
+}
+
+// point index with its squared distance to a query point
+type kdCandidate struct {
+	index    int
+	distance float64
+}
+
+func (tree *KDTree) kNearest(node *kdNode, query Point3D, k int, nearest *[]kdCandidate) {
+	if node == nil {
+		return
+	}
+	point := tree.points[node.index]
+	d := point.Sub(query)
+	distance := d.Dot(d)
+	// insert the point keeping the candidates sorted, and drop the farthest if there are more than k
+	if len(*nearest) < k || distance < (*nearest)[len(*nearest)-1].distance {
+		position := sort.Search(len(*nearest), func(i int) bool { return (*nearest)[i].distance > distance })
+		*nearest = append(*nearest, kdCandidate{})
+		copy((*nearest)[position+1:], (*nearest)[position:])
+		(*nearest)[position] = kdCandidate{node.index, distance}
+		if len(*nearest) > k {
+			*nearest = (*nearest)[:k]
+		}
+	}
+	// search the side of the query point first, and the other side only if it can hold closer points
+	delta := coordinate(query, node.axis) - coordinate(point, node.axis)
+	first, second := node.left, node.right
+	if delta > 0 {
+		first, second = node.right, node.left
+	}
+	tree.kNearest(first, query, k, nearest)
+	if len(*nearest) < k || delta*delta < (*nearest)[len(*nearest)-1].distance {
+		tree.kNearest(second, query, k, nearest)
+	}
+}
+
+// returns the coordinate of a point along given axis (0: x, 1: y, 2: z)
+func coordinate(point Point3D, axis int) float64 {
+	switch axis {
+	case 0:
+		return point.X
+	case 1:
+		return point.Y
+	default:
+		return point.Z
+	}
+}
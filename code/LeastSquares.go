@@ -0,0 +1,71 @@
+package code
+
+import (
+	"errors"
+	"math"
+)
+
+// solves the linear system a x = b using Gaussian elimination with partial pivoting
+// returns an error if the matrix is singular
+func solveLinear(a [][]float64, b []float64) ([]float64, error) {
+	n := len(b)
+	// work on copies, the system is augmented with b as last column
+	m := make([][]float64, n)
+	for i := range m {
+		m[i] = append(append([]float64{}, a[i]...), b[i])
+	}
+
+	for col := 0; col < n; col++ {
+		// pick the row with the largest pivot
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(m[row][col]) > math.Abs(m[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(m[pivot][col]) < 1e-12 {
+			return nil, errors.New("singular linear system")
+		}
+		m[col], m[pivot] = m[pivot], m[col]
+		// eliminate the column below the pivot
+		for row := col + 1; row < n; row++ {
+			factor := m[row][col] / m[col][col]
+			for k := col; k <= n; k++ {
+				m[row][k] -= factor * m[col][k]
+			}
+		}
+	}
+
+	// back substitution
+	x := make([]float64, n)
+	for row := n - 1; row >= 0; row-- {
+		sum := m[row][n]
+		for k := row + 1; k < n; k++ {
+			sum -= m[row][k] * x[k]
+		}
+		x[row] = sum / m[row][row]
+	}
+	return x, nil
+}
+
+// solves the overdetermined linear system given by its rows in the least squares sense using the normal equations
+func solveLeastSquares(rows [][]float64, rhs []float64) ([]float64, error) {
+	if len(rows) == 0 {
+		return nil, errors.New("no equations")
+	}
+	n := len(rows[0])
+	ata := make([][]float64, n)
+	for i := range ata {
+		ata[i] = make([]float64, n)
+	}
+	atb := make([]float64, n)
+	for r, row := range rows {
+		for i := 0; i < n; i++ {
+			atb[i] += row[i] * rhs[r]
+			for j := 0; j < n; j++ {
+				ata[i][j] += row[i] * row[j]
+			}
+		}
+	}
+	return solveLinear(ata, atb)
+}
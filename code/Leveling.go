@@ -0,0 +1,141 @@
+package code
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+)
+
+// options of the ground leveling
+type LevelOptions struct {
+	// maximum angle in degrees between the normal of the ground and the vertical (0 accepts any plane)
+	MaxTilt float64
+	// use this multiple of the noise estimated from the point cloud as eps (0 uses eps)
+	EpsSigmaFactor float64
+	// number of neighbours used to estimate the noise
+	NormalNeighbours int
+	// transform applied to the point cloud before leveling it (nil if none)
+	Transform *RigidTransform
+}
+
+// finds the ground plane, the dominant plane of the point cloud, whose normal is within maxTilt degrees of the
+// vertical if maxTilt is positive, refitted to its supporting points
+// returns an error if no plane is found
+func FindGroundPlane(numOfIterations int, pointCloud PointCloud, eps, maxTilt float64) (Plane3DwSupport, error) {
+	model := PlaneModel{}
+	if maxTilt > 0 {
+		model.Orientation = OrientationConstraint{Axis: Point3D{0, 0, 1}, MaxAngle: maxTilt}
+	}
+	plane, _ := Engine[Plane3D]{model, EngineOptions{Refit: true}}.DominantModelIdentifier(numOfIterations, pointCloud, eps)
+	ground := newPlane3DwSupport(plane)
+	if ground.SupportSize <= 0 {
+		return ground, errors.New("no ground plane found")
+	}
+	return ground, nil
+}
+
+// orients a plane so that its normal points up, up being the side of the plane with the most points of the cloud
+// farther than eps
+func GetUpwardPlane(plane Plane3D, pointCloud *PointCloud, eps float64) Plane3D {
+	normal, h := plane.getHessianForm()
+
+	// the points above the plane are on the side of the normal
+	above, below := 0, 0
+	for i := range pointCloud.points {
+		distance := normal.Dot(pointCloud.points[i]) - h
+		if distance > eps {
+			above++
+		} else if distance < -eps {
+			below++
+		}
+	}
+	if below > above {
+		return Plane3D{-plane.A, -plane.B, -plane.C, -plane.D}
+	}
+	return plane
+}
+
+// computes the rigid transform mapping the ground plane to z = 0 with its normal pointing up, up being the side of
+// the plane with the most points of the cloud farther than eps
+// the rotation is the smallest one aligning the normal with the z axis, so that the x and y axes keep their heading
+func GetLevelingTransform(ground Plane3D, pointCloud *PointCloud, eps float64) RigidTransform {
+	upward := GetUpwardPlane(ground, pointCloud, eps)
+	normal, h := upward.getHessianForm()
+
+	// the rotated ground is the plane z = h, moved down to z = 0
+	return RigidTransform{Rotation: RotationBetween(normal, Point3D{0, 0, 1}), Translation: Point3D{0, 0, -h}}
+}
+
+// levels the point cloud, mapping its ground plane to z = 0 with the normal pointing up
+// returns the leveled point cloud, the transform and the ground plane before the transform
+func LevelGround(numOfIterations int, pointCloud PointCloud, eps, maxTilt float64) (PointCloud, RigidTransform, Plane3DwSupport, error) {
+	ground, err := FindGroundPlane(numOfIterations, pointCloud, eps, maxTilt)
+	if err != nil {
+		return pointCloud, RigidTransform{}, ground, err
+	}
+	transform := GetLevelingTransform(ground.Plane3D, &pointCloud, eps)
+	return pointCloud.Transform(transform), transform, ground, nil
+}
+
+// method to level the point cloud of a file, saving the leveled point cloud and the transform to files
+func Level(filename string, confidence, percentageOfPointsOnPlane, eps float64, options ...LevelOptions) {
+	fmt.Println("Initiating ground leveling")
+	// use default options if none provided
+	if len(options) == 0 {
+		options = []LevelOptions{{}}
+	}
+
+	// get the PointCloud
+	pointCloud, err := readXYZ(filename)
+	if err != nil {
+		fmt.Println("Unable to get Point Cloud", err)
+		os.Exit(1)
+	}
+	fmt.Println("Point Cloud size: ", len(pointCloud.points))
+
+	// move the point cloud to the frame it is leveled in
+	if options[0].Transform != nil {
+		pointCloud = pointCloud.Transform(*options[0].Transform)
+		fmt.Println("Point Cloud transformed")
+	}
+
+	// estimate eps from the noise of the point cloud if requested
+	if options[0].EpsSigmaFactor > 0 {
+		sigma := pointCloud.EstimateNoise(options[0].NormalNeighbours)
+		eps, err = getNoiseEps(sigma, options[0].EpsSigmaFactor, eps)
+		if err != nil {
+			fmt.Println("Unable to estimate epsilon", err)
+			os.Exit(1)
+		}
+		fmt.Println("Estimated epsilon: ", eps)
+	}
+
+	numOfIterations := getNumberOfIterations(confidence, percentageOfPointsOnPlane)
+	leveled, transform, ground, err := LevelGround(numOfIterations, pointCloud, eps, options[0].MaxTilt)
+	if err != nil {
+		fmt.Println("Unable to level point cloud", err)
+		os.Exit(1)
+	}
+	// the saved transform maps the input point cloud to the leveled one
+	if options[0].Transform != nil {
+		transform = options[0].Transform.Then(transform)
+	}
+	tilt := math.Acos(math.Min(math.Abs(ground.GetUnitNormal().Z), 1)) * 180 / math.Pi
+	fmt.Printf("Ground plane: %v, %d points, tilted by %f degrees\n", ground.Plane3D, ground.SupportSize, tilt)
+	fmt.Println("Leveling transform: ", transform.Matrix())
+
+	// save the leveled point cloud and the transform
+	err = saveXYZ(getOutputFilename(filename, "_leveled.xyz"), leveled.points)
+	if err != nil {
+		fmt.Println("Unable to save leveled point cloud", err)
+		os.Exit(1)
+	}
+	err = SaveTransform(getOutputFilename(filename, "_level.txt"), transform)
+	if err != nil {
+		fmt.Println("Unable to save leveling transform", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("Program completed successfully :)")
+}
@@ -0,0 +1,81 @@
+package code
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// number of points needed to define a line
+const LINE_SAMPLE_SIZE int = 2
+
+// Line3D represents a 3D line
+type Line3D struct {
+	// point on the line, the start of the segment once bounded to its supporting points
+	Point Point3D
+	// unit direction of the line
+	Direction Point3D
+	// length of the segment (0 until bounded to its supporting points)
+	Length float64
+}
+
+// computes the line passing through 2 points
+// returns an error if the points are identical
+func GetLine(p1, p2 Point3D) (Line3D, error) {
+	direction := p2.Sub(p1)
+	if direction.Norm() == 0 {
+		return Line3D{}, errors.New("points are identical")
+	}
+	return Line3D{Point: p1, Direction: direction.Normalize()}, nil
+}
+
+// calculate perpendicular distance of a point to the line
+func (l *Line3D) GetDistance(point *Point3D) float64 {
+	v := (*point).Sub(l.Point)
+	return v.Sub(l.Direction.Scale(v.Dot(l.Direction))).Norm()
+}
+
+// bounds the line to the segment covering the points
+func (l *Line3D) SetExtent(points []Point3D) {
+	if len(points) == 0 {
+		return
+	}
+	low, high := math.Inf(1), math.Inf(-1)
+	for _, point := range points {
+		t := point.Sub(l.Point).Dot(l.Direction)
+		low = math.Min(low, t)
+		high = math.Max(high, t)
+	}
+	l.Point = l.Point.Add(l.Direction.Scale(low))
+	l.Length = high - low
+}
+
+// string representation of a Line3D
+func (l Line3D) String() string {
+	return fmt.Sprintf("point=(%v), direction=(%v), length=%f", l.Point, l.Direction, l.Length)
+}
+
+// LineModel is the Model of lines, fitted to samples of 2 points
+type LineModel struct{}
+
+func (m LineModel) SampleSize() int {
+	return LINE_SAMPLE_SIZE
+}
+
+func (m LineModel) IsDegenerate(sample []Point3D) bool {
+	return sample[0] == sample[1]
+}
+
+func (m LineModel) Fit(sample []Point3D) (Line3D, error) {
+	return GetLine(sample[0], sample[1])
+}
+
+func (m LineModel) Residual(line Line3D, point *Point3D) float64 {
+	return line.GetDistance(point)
+}
+
+// least squares line of the points, through their centroid along the direction of most variance
+func (m LineModel) Refit(line Line3D, points []Point3D) (Line3D, error) {
+	centroid, _, axes := getPrincipalAxes(points)
+	return Line3D{Point: centroid, Direction: axes[2]}, nil
+}
@@ -0,0 +1,77 @@
+package code
+
+import (
+	"math/rand"
+)
+
+// number of inner RANSAC iterations of a local optimization
+const LO_INNER_ITERATIONS int = 10
+
+// the inner samples contain at most LO_INNER_SAMPLE_FACTOR times the size of the minimal samples
+const LO_INNER_SAMPLE_FACTOR int = 7
+
+// threshold of the first refit of the iterative refit, as a multiple of eps
+const LO_THRESHOLD_MULTIPLIER float64 = 4
+
+// number of refits of the iterative refit, the threshold shrinking linearly from LO_THRESHOLD_MULTIPLIER times eps to eps
+const LO_REFIT_STEPS int = 4
+
+// locally optimizes a hypothesis which beats the best so far (LO-RANSAC)
+// an inner RANSAC draws non-minimal samples from the supporting points of the best hypothesis, each refitted primitive
+// being improved by iterative refits with shrinking thresholds
+// returns the primitive with the most supporting points, which is the hypothesis itself if none improves on it
+func (e Engine[T]) localOptimization(hypothesis ModelWithSupport[T], pointCloud *PointCloud, eps float64, refitter Refitter[T]) ModelWithSupport[T] {
+	best := e.iterativeRefit(hypothesis, pointCloud, eps, refitter)
+
+	// size of the inner samples
+	sampleSize := LO_INNER_SAMPLE_FACTOR * e.Model.SampleSize()
+	if sampleSize > hypothesis.SupportSize/2 {
+		sampleSize = hypothesis.SupportSize / 2
+	}
+	for i := 0; i < LO_INNER_ITERATIONS && sampleSize >= e.Model.SampleSize(); i++ {
+		sample := []Point3D{}
+		for _, index := range distinctIndices(best.SupportSize, sampleSize, rand.Intn) {
+			sample = append(sample, best.SupportingPoints[index])
+		}
+		primitive, err := refitter.Refit(best.Model, sample)
+		if err != nil {
+			continue
+		}
+		candidate := e.iterativeRefit(GetModelSupport(pointCloud, e.Model, primitive, eps), pointCloud, eps, refitter)
+		if candidate.SupportSize > best.SupportSize {
+			best = candidate
+		}
+	}
+
+	if e.Stats != nil {
+		e.Stats.LocalOptimizations++
+		if best.SupportSize > hypothesis.SupportSize {
+			e.Stats.LocalImprovements++
+			e.Stats.LocalSupportGain += best.SupportSize - hypothesis.SupportSize
+		}
+	}
+	return best
+}
+
+// refits the primitive to the points within thresholds shrinking from LO_THRESHOLD_MULTIPLIER times eps to eps
+// returns the primitive with the most supporting points within eps among the refitted ones and the initial one
+func (e Engine[T]) iterativeRefit(initial ModelWithSupport[T], pointCloud *PointCloud, eps float64, refitter Refitter[T]) ModelWithSupport[T] {
+	best := initial
+	primitive := initial.Model
+	for step := 0; step < LO_REFIT_STEPS; step++ {
+		threshold := eps * (LO_THRESHOLD_MULTIPLIER - (LO_THRESHOLD_MULTIPLIER-1)*float64(step)/float64(LO_REFIT_STEPS-1))
+		inliers := GetModelSupport(pointCloud, e.Model, primitive, threshold)
+		if inliers.SupportSize <= e.Model.SampleSize() {
+			break
+		}
+		refitted, err := refitter.Refit(primitive, inliers.SupportingPoints)
+		if err != nil {
+			break
+		}
+		primitive = refitted
+		if support := GetModelSupport(pointCloud, e.Model, primitive, eps); support.SupportSize > best.SupportSize {
+			best = support
+		}
+	}
+	return best
+}
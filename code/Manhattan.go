@@ -0,0 +1,197 @@
+package code
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// number of points whose normals are used to estimate the Manhattan frame
+const MANHATTAN_NORMAL_SAMPLES int = 5000
+
+// number of frame hypotheses, each defined by 2 nearly orthogonal normals
+const MANHATTAN_HYPOTHESES int = 500
+
+// number of refinements of the best frame from the normals aligned with its axes
+const MANHATTAN_REFINEMENTS int = 3
+
+// ManhattanFrame is the orthonormal frame of the 3 dominant mutually orthogonal directions of a scene
+// the axes are right handed, the third one being the closest to the vertical and pointing up
+type ManhattanFrame struct {
+	Axes [3]Point3D
+}
+
+// rotation matrix from the coordinates of the point cloud to the coordinates of the frame, whose rows are the axes
+func (f ManhattanFrame) Rotation() Matrix3 {
+	rotation := Matrix3{}
+	for i, axis := range f.Axes {
+		rotation[i] = [3]float64{axis.X, axis.Y, axis.Z}
+	}
+	return rotation
+}
+
+// returns the index of the axis closest to a unit direction, and the absolute cosine of their angle
+func (f ManhattanFrame) closestAxis(direction Point3D) (int, float64) {
+	best, bestCosine := 0, -1.0
+	for i, axis := range f.Axes {
+		if cosine := math.Abs(axis.Dot(direction)); cosine > bestCosine {
+			best, bestCosine = i, cosine
+		}
+	}
+	return best, bestCosine
+}
+
+// estimates the Manhattan frame of the point cloud from the normals of a sample of flat points
+// frames are hypothesized from pairs of nearly orthogonal normals, the frame aligned with the most normals within
+// maxAngle degrees (0 uses DEFAULT_ORIENTATION_ANGLE) being refined from the normals aligned with each of its axes
+// normals are estimated from the k nearest neighbours of the points (0 uses DEFAULT_NORMAL_NEIGHBOURS)
+// returns an error if the point cloud has no pair of orthogonal surfaces
+func (pointCloud *PointCloud) EstimateManhattanFrame(k int, maxAngle float64) (ManhattanFrame, error) {
+	if k <= 0 {
+		k = DEFAULT_NORMAL_NEIGHBOURS
+	}
+	if maxAngle <= 0 {
+		maxAngle = DEFAULT_ORIENTATION_ANGLE
+	}
+	minCosine := math.Cos(maxAngle * math.Pi / 180)
+	maxCosine := math.Sin(maxAngle * math.Pi / 180)
+
+	// normals of a sample of the flat points
+	tree := NewKDTree(pointCloud.points)
+	normals := []Point3D{}
+	neighbourhood := make([]Point3D, 0, k)
+	for _, index := range rand.Perm(len(pointCloud.points)) {
+		if len(normals) == MANHATTAN_NORMAL_SAMPLES {
+			break
+		}
+		neighbourhood = neighbourhood[:0]
+		for _, neighbour := range tree.KNearest(pointCloud.points[index], k) {
+			neighbourhood = append(neighbourhood, pointCloud.points[neighbour])
+		}
+		if normal, curvature := getNormalAndCurvature(neighbourhood); curvature < DEFAULT_CURVATURE_THRESHOLD {
+			normals = append(normals, normal)
+		}
+	}
+	if len(normals) < 2 {
+		return ManhattanFrame{}, errors.New("not enough flat points to estimate the Manhattan frame")
+	}
+
+	// number of normals aligned with an axis of a frame
+	support := func(frame ManhattanFrame) int {
+		count := 0
+		for _, normal := range normals {
+			if _, cosine := frame.closestAxis(normal); cosine >= minCosine {
+				count++
+			}
+		}
+		return count
+	}
+
+	// frame supported by the most normals
+	best, bestSupport := ManhattanFrame{}, 0
+	for i := 0; i < MANHATTAN_HYPOTHESES; i++ {
+		pair := distinctIndices(len(normals), 2, rand.Intn)
+		n1, n2 := normals[pair[0]], normals[pair[1]]
+		if math.Abs(n1.Dot(n2)) > maxCosine {
+			continue
+		}
+		frame := getOrthonormalFrame(n1, n2)
+		if count := support(frame); count > bestSupport {
+			best, bestSupport = frame, count
+		}
+	}
+	if bestSupport == 0 {
+		return ManhattanFrame{}, errors.New("no orthogonal surfaces to estimate the Manhattan frame")
+	}
+
+	// refine the frame from the mean normal aligned with each axis, the axes with the most normals coming first
+	for r := 0; r < MANHATTAN_REFINEMENTS; r++ {
+		means := [3]Point3D{}
+		counts := [3]int{}
+		for _, normal := range normals {
+			axis, cosine := best.closestAxis(normal)
+			if cosine < minCosine {
+				continue
+			}
+			// normals have no sign
+			if normal.Dot(best.Axes[axis]) < 0 {
+				normal = normal.Scale(-1)
+			}
+			means[axis] = means[axis].Add(normal)
+			counts[axis]++
+		}
+		order := []int{0, 1, 2}
+		sort.SliceStable(order, func(i, j int) bool {
+			return counts[order[i]] > counts[order[j]]
+		})
+		if counts[order[1]] == 0 {
+			break
+		}
+		best = getOrthonormalFrame(means[order[0]], means[order[1]])
+	}
+
+	return best.upright(), nil
+}
+
+// computes the right handed orthonormal frame whose first axis is along u and second axis in the plane of u and v
+func getOrthonormalFrame(u, v Point3D) ManhattanFrame {
+	a := u.Normalize()
+	b := v.Sub(a.Scale(v.Dot(a))).Normalize()
+	return ManhattanFrame{[3]Point3D{a, b, a.Cross(b)}}
+}
+
+// reorders the axes of the frame so that the third one is the closest to the vertical and points up
+func (f ManhattanFrame) upright() ManhattanFrame {
+	up, _ := f.closestAxis(Point3D{0, 0, 1})
+	axes := [3]Point3D{f.Axes[(up+1)%3], f.Axes[(up+2)%3], f.Axes[up]}
+	if axes[2].Z < 0 {
+		// flipping 2 axes keeps the frame right handed
+		axes[1] = axes[1].Scale(-1)
+		axes[2] = axes[2].Scale(-1)
+	}
+	return ManhattanFrame{axes}
+}
+
+// ManhattanModel is the Model of planes whose normal is one of the axes of a Manhattan frame
+// the plane fitted to a sample is snapped to the closest axis, and rejected if its normal is more than MaxAngle
+// degrees (0 uses DEFAULT_ORIENTATION_ANGLE) away from it
+type ManhattanModel struct {
+	Frame    ManhattanFrame
+	MaxAngle float64
+}
+
+func (m ManhattanModel) SampleSize() int {
+	return 3
+}
+
+func (m ManhattanModel) IsDegenerate(sample []Point3D) bool {
+	return isCollinear(sample[0], sample[1], sample[2])
+}
+
+func (m ManhattanModel) Fit(sample []Point3D) (Plane3D, error) {
+	plane := GetPlane(sample[0], sample[1], sample[2])
+	maxAngle := m.MaxAngle
+	if maxAngle <= 0 {
+		maxAngle = DEFAULT_ORIENTATION_ANGLE
+	}
+	axis, cosine := m.Frame.closestAxis(plane.GetUnitNormal())
+	if cosine < math.Cos(maxAngle*math.Pi/180) {
+		return plane, errors.New("plane normal not aligned with the Manhattan frame")
+	}
+	return getPlaneWithNormal(m.Frame.Axes[axis], GetCentroid(sample)), nil
+}
+
+func (m ManhattanModel) Residual(plane Plane3D, point *Point3D) float64 {
+	return plane.GetDistance(point)
+}
+
+// least squares plane of the points with the normal of the plane
+func (m ManhattanModel) Refit(plane Plane3D, points []Point3D) (Plane3D, error) {
+	return getPlaneWithNormal(plane.GetUnitNormal(), GetCentroid(points)), nil
+}
+
+// computes the plane with given unit normal passing through a point
+func getPlaneWithNormal(normal, point Point3D) Plane3D {
+	return Plane3D{normal.X, normal.Y, normal.Z, -normal.Dot(point)}
+}
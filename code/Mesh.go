@@ -0,0 +1,239 @@
+package code
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// mesh file formats
+const (
+	MESH_FORMAT_OBJ        = "obj"
+	MESH_FORMAT_STL        = "stl"
+	MESH_FORMAT_STL_BINARY = "stl-binary"
+	MESH_FORMAT_PLY        = "ply"
+)
+
+// Mesh is a named triangulated surface lying in a plane
+type Mesh struct {
+	Name     string
+	Vertices []Point3D
+	// indices of the vertices of each triangle, counterclockwise around the normal
+	Triangles [][3]int
+	// unit normal of the plane
+	Normal Point3D
+}
+
+// triangulates the outline of the supporting points of the plane, the largest loop of the concave hull with given
+// alpha, or the convex hull if alpha is not positive; holes of the outline are not cut from the mesh
+func (plane *Plane3DwSupport) GetMesh(name string, alpha float64) Mesh {
+	frame := plane.GetFrame()
+	outline := plane.GetBoundary(alpha).getOutline()
+	return Mesh{
+		Name:      name,
+		Vertices:  outline,
+		Triangles: TriangulatePolygon2D(frame.ToPlane2D(outline)),
+		Normal:    frame.Normal,
+	}
+}
+
+// area of the triangles of the mesh
+func (m Mesh) Area() float64 {
+	area := 0.0
+	for _, triangle := range m.Triangles {
+		a, b, c := m.Vertices[triangle[0]], m.Vertices[triangle[1]], m.Vertices[triangle[2]]
+		area += b.Sub(a).Cross(c.Sub(a)).Norm() / 2
+	}
+	return area
+}
+
+// method to save meshes to a file of given format, the meshes being kept apart as groups, solids or face properties
+func SaveMeshes(filename string, meshes []Mesh, format string) error {
+	if filename == "" {
+		return errors.New("no filename provided")
+	}
+
+	fmt.Println("Saving file: " + filename)
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return errors.New("could not open file")
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	switch format {
+	case MESH_FORMAT_OBJ:
+		err = writeMeshOBJ(writer, filename, meshes)
+	case MESH_FORMAT_STL:
+		err = writeMeshSTL(writer, meshes)
+	case MESH_FORMAT_STL_BINARY:
+		err = writeMeshBinarySTL(writer, meshes)
+	case MESH_FORMAT_PLY:
+		err = writeMeshPLY(writer, meshes)
+	default:
+		err = fmt.Errorf("unknown mesh format: %s", format)
+	}
+	if err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+// returns the file extension of a mesh format
+func GetMeshExtension(format string) string {
+	if format == MESH_FORMAT_STL_BINARY {
+		return ".stl"
+	}
+	return "." + format
+}
+
+// writes the meshes as Wavefront OBJ groups, each with its own material defined in a .mtl file next to the OBJ file
+func writeMeshOBJ(writer *bufio.Writer, filename string, meshes []Mesh) error {
+	materialFilename := strings.TrimSuffix(filename, filepath.Ext(filename)) + ".mtl"
+	err := saveMaterials(materialFilename, meshes)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(writer, "mtllib %s\n", filepath.Base(materialFilename))
+
+	// OBJ indices start at 1 and run over all the meshes
+	offset := 1
+	for i, mesh := range meshes {
+		fmt.Fprintf(writer, "g %s\nusemtl %s\n", mesh.Name, mesh.Name)
+		for _, vertex := range mesh.Vertices {
+			fmt.Fprintf(writer, "v %f %f %f\n", vertex.X, vertex.Y, vertex.Z)
+		}
+		fmt.Fprintf(writer, "vn %f %f %f\n", mesh.Normal.X, mesh.Normal.Y, mesh.Normal.Z)
+		for _, triangle := range mesh.Triangles {
+			fmt.Fprintf(writer, "f %d//%d %d//%d %d//%d\n", triangle[0]+offset, i+1, triangle[1]+offset, i+1, triangle[2]+offset, i+1)
+		}
+		offset += len(mesh.Vertices)
+	}
+	return nil
+}
+
+// method to save a material of distinct color for each mesh to a Wavefront MTL file
+func saveMaterials(filename string, meshes []Mesh) error {
+	fmt.Println("Saving file: " + filename)
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return errors.New("could not open file")
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	for i, mesh := range meshes {
+		r, g, b := getDistinctColor(i)
+		fmt.Fprintf(writer, "newmtl %s\nKd %f %f %f\n\n", mesh.Name, r, g, b)
+	}
+	return writer.Flush()
+}
+
+// returns the i-th color of a sequence of colors whose hues are spread by the golden angle
+func getDistinctColor(i int) (float64, float64, float64) {
+	hue := math.Mod(float64(i)*0.618033988749895, 1) * 6
+	x := 1 - math.Abs(math.Mod(hue, 2)-1)
+	switch int(hue) {
+	case 0:
+		return 1, x, 0
+	case 1:
+		return x, 1, 0
+	case 2:
+		return 0, 1, x
+	case 3:
+		return 0, x, 1
+	case 4:
+		return x, 0, 1
+	default:
+		return 1, 0, x
+	}
+}
+
+// writes the meshes as ASCII STL solids
+func writeMeshSTL(writer *bufio.Writer, meshes []Mesh) error {
+	for _, mesh := range meshes {
+		fmt.Fprintf(writer, "solid %s\n", mesh.Name)
+		for _, triangle := range mesh.Triangles {
+			fmt.Fprintf(writer, "  facet normal %e %e %e\n    outer loop\n", mesh.Normal.X, mesh.Normal.Y, mesh.Normal.Z)
+			for _, vertex := range triangle {
+				point := mesh.Vertices[vertex]
+				fmt.Fprintf(writer, "      vertex %e %e %e\n", point.X, point.Y, point.Z)
+			}
+			fmt.Fprintf(writer, "    endloop\n  endfacet\n")
+		}
+		fmt.Fprintf(writer, "endsolid %s\n", mesh.Name)
+	}
+	return nil
+}
+
+// writes the meshes as a single binary STL solid, whose 80 byte header names the meshes
+func writeMeshBinarySTL(writer *bufio.Writer, meshes []Mesh) error {
+	names := []string{}
+	count := 0
+	for _, mesh := range meshes {
+		names = append(names, mesh.Name)
+		count += len(mesh.Triangles)
+	}
+	header := make([]byte, 80)
+	copy(header, strings.Join(names, " "))
+	if _, err := writer.Write(header); err != nil {
+		return err
+	}
+	if err := binary.Write(writer, binary.LittleEndian, uint32(count)); err != nil {
+		return err
+	}
+
+	for _, mesh := range meshes {
+		for _, triangle := range mesh.Triangles {
+			facet := [12]float32{float32(mesh.Normal.X), float32(mesh.Normal.Y), float32(mesh.Normal.Z)}
+			for k, vertex := range triangle {
+				point := mesh.Vertices[vertex]
+				facet[3+3*k], facet[4+3*k], facet[5+3*k] = float32(point.X), float32(point.Y), float32(point.Z)
+			}
+			if err := binary.Write(writer, binary.LittleEndian, facet); err != nil {
+				return err
+			}
+			// attribute byte count
+			if err := binary.Write(writer, binary.LittleEndian, uint16(0)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writes the meshes as an ASCII PLY file with faces, the index of the mesh of each face being a face property
+func writeMeshPLY(writer *bufio.Writer, meshes []Mesh) error {
+	vertices, faces := 0, 0
+	for _, mesh := range meshes {
+		vertices += len(mesh.Vertices)
+		faces += len(mesh.Triangles)
+	}
+	fmt.Fprintf(writer, "ply\nformat ascii 1.0\n")
+	for i, mesh := range meshes {
+		fmt.Fprintf(writer, "comment plane %d %s\n", i, mesh.Name)
+	}
+	fmt.Fprintf(writer, "element vertex %d\nproperty float x\nproperty float y\nproperty float z\n", vertices)
+	fmt.Fprintf(writer, "element face %d\nproperty list uchar int vertex_indices\nproperty int plane\nend_header\n", faces)
+
+	for _, mesh := range meshes {
+		for _, vertex := range mesh.Vertices {
+			fmt.Fprintf(writer, "%f %f %f\n", vertex.X, vertex.Y, vertex.Z)
+		}
+	}
+	offset := 0
+	for i, mesh := range meshes {
+		for _, triangle := range mesh.Triangles {
+			fmt.Fprintf(writer, "3 %d %d %d %d\n", triangle[0]+offset, triangle[1]+offset, triangle[2]+offset, i)
+		}
+		offset += len(mesh.Vertices)
+	}
+	return nil
+}
@@ -0,0 +1,306 @@
+package code
+
+import (
+	"sync/atomic"
+)
+
+// Model describes a kind of geometric primitive of type T which can be detected by RANSAC
+type Model[T any] interface {
+	// number of points of a minimal sample
+	SampleSize() int
+	// reports whether a minimal sample cannot define a primitive
+	IsDegenerate(sample []Point3D) bool
+	// computes the primitive defined by a minimal sample
+	// returns an error if the primitive violates the constraints of the model
+	Fit(sample []Point3D) (T, error)
+	// distance of a point to the primitive
+	Residual(primitive T, point *Point3D) float64
+}
+
+// Refitter is implemented by models whose primitives can be re-estimated from all their supporting points
+type Refitter[T any] interface {
+	Refit(primitive T, points []Point3D) (T, error)
+}
+
+// primitives of unbounded extent, such as cylinders, are bounded to their supporting points once detected
+type boundedShape interface {
+	SetExtent(points []Point3D)
+}
+
+// primitive with supporting points
+type ModelWithSupport[T any] struct {
+	Model            T
+	SupportSize      int
+	SupportingPoints []Point3D
+}
+
+// EngineOptions configure the generic RANSAC engine
+type EngineOptions struct {
+	// creates the sampler drawing the minimal samples (uniform sampling if nil)
+	Sampler SamplerFactory
+	// re-estimates the best primitive from all its supporting points, for models implementing Refitter
+	Refit bool
+	// locally optimizes each hypothesis beating the best so far (LO-RANSAC), for models implementing Refitter
+	LocalOptimization bool
+	// rejects hypotheses unlikely to beat the best so far before all points are checked (SPRT)
+	EarlyRejection bool
+	// counters of the optional stages, updated by the runs of the engine if not nil
+	Stats *EngineStats
+}
+
+// EngineStats counts what the optional stages of the RANSAC engine did over a run
+type EngineStats struct {
+	// number of local optimizations run, and how many of them improved the support of the hypothesis
+	LocalOptimizations int `json:"local_optimizations"`
+	LocalImprovements  int `json:"local_improvements"`
+	// number of supporting points gained by local optimizations
+	LocalSupportGain int `json:"local_support_gain"`
+	// number of hypotheses verified with early rejection, of rejected ones, and of points checked
+	VerifiedHypotheses int `json:"verified_hypotheses,omitempty"`
+	RejectedHypotheses int `json:"rejected_hypotheses,omitempty"`
+	CheckedPoints      int `json:"checked_points,omitempty"`
+}
+
+// Engine runs RANSAC for a model
+type Engine[T any] struct {
+	Model Model[T]
+	EngineOptions
+}
+
+// sends the values received from the inbound channel converted by convert on the outbound channel
+func convertC[S, T any](in <-chan S, convert func(S) T) <-chan T {
+	// outbound channel
+	out := make(chan T)
+	// goroutine to convert the values
+	go func() {
+		defer close(out)
+		for value := range in {
+			out <- convert(value)
+		}
+	}()
+	// return the outbound channel
+	return out
+}
+
+// receives samples of points and sends back the primitives fitted by the model through output channel
+// samples smaller than the minimal sample, degenerate samples and rejected primitives are dropped
+func GetModelC[T any](samplesIn <-chan []Point3D, model Model[T]) <-chan T {
+	return getModelC(samplesIn, model, nil)
+}
+
+// GetModelC ignoring the samples received once their number exceeds limit (if not nil)
+func getModelC[T any](samplesIn <-chan []Point3D, model Model[T], limit *atomic.Int64) <-chan T {
+	dprint("********** GetModelC started **********")
+	// outbound channel
+	modelOut := make(chan T)
+	// goroutine to compute the primitives
+	go func() {
+		defer close(modelOut)
+		defer dprint("********** GetModelC done **********")
+		// number of samples received
+		count := int64(0)
+		for sample := range samplesIn {
+			count++
+			// drain the remaining samples so that the sampling goroutines end
+			if limit != nil && count > limit.Load() {
+				continue
+			}
+			if len(sample) < model.SampleSize() || model.IsDegenerate(sample) {
+				continue
+			}
+			primitive, err := model.Fit(sample)
+			if err != nil {
+				continue
+			}
+			modelOut <- primitive
+		}
+	}()
+	// return the outbound channel
+	return modelOut
+}
+
+// receives primitives from inbound channel
+// sends back ModelWithSupport instances containing the primitive and its supporting points in the point cloud
+func GetModelSupportingPointsC[T any](pointCloud *PointCloud, primitiveIn <-chan T, model Model[T], eps float64) <-chan ModelWithSupport[T] {
+	dprint("********** GetModelSupportingPointsC started **********")
+	// outbound channel
+	primitiveOut := make(chan ModelWithSupport[T])
+	// goroutine to get supporting points
+	go func() {
+		defer close(primitiveOut)
+		defer dprint("********** GetModelSupportingPointsC done **********")
+		for primitive := range primitiveIn {
+			primitiveOut <- GetModelSupport(pointCloud, model, primitive, eps)
+		}
+	}()
+	// return the outbound channel
+	return primitiveOut
+}
+
+// returns the primitive with the points of the point cloud within eps of it
+func GetModelSupport[T any](pointCloud *PointCloud, model Model[T], primitive T, eps float64) ModelWithSupport[T] {
+	supportingPoints := make([]Point3D, 0)
+	for _, point := range pointCloud.points {
+		if model.Residual(primitive, &point) <= eps {
+			supportingPoints = append(supportingPoints, point)
+		}
+	}
+	return ModelWithSupport[T]{
+		Model:            primitive,
+		SupportSize:      len(supportingPoints),
+		SupportingPoints: supportingPoints,
+	}
+}
+
+// receives ModelWithSupport instances from inbound channel and sends back the one with the most supporting points
+// onBest (if not nil) is called with each primitive improving on the best so far and returns the primitive to keep
+// the support size of the result is -1 if no primitive was received
+func modelFanIn[T any](supportingPointsIn <-chan ModelWithSupport[T], onBest func(ModelWithSupport[T]) ModelWithSupport[T]) <-chan ModelWithSupport[T] {
+	// outbound channel
+	bestOut := make(chan ModelWithSupport[T])
+	// goroutine to find the best primitive
+	go func() {
+		defer close(bestOut)
+		best := ModelWithSupport[T]{SupportSize: -1}
+		for primitive := range supportingPointsIn {
+			if primitive.SupportSize > best.SupportSize {
+				best = primitive
+				if onBest != nil {
+					best = onBest(best)
+				}
+			}
+		}
+		bestOut <- best
+	}()
+	// return the outbound channel
+	return bestOut
+}
+
+// identifies the primitive with most supporting points among those fitted to numOfIterations random samples
+// returns false if no valid primitive could be fitted
+func DominantModelIdentifier[T any](numOfIterations int, pointCloud PointCloud, eps float64, model Model[T]) (ModelWithSupport[T], bool) {
+	return Engine[T]{Model: model}.DominantModelIdentifier(numOfIterations, pointCloud, eps)
+}
+
+// identifies the primitive with most supporting points among those fitted to at most numOfIterations samples
+// if Refit is set and the model implements Refitter, the best primitive is re-estimated from its supporting points
+// returns false if no valid primitive could be fitted, or the point cloud has fewer points than a minimal sample
+func (e Engine[T]) DominantModelIdentifier(numOfIterations int, pointCloud PointCloud, eps float64) (ModelWithSupport[T], bool) {
+	if len(pointCloud.points) < e.Model.SampleSize() {
+		return ModelWithSupport[T]{SupportSize: -1}, false
+	}
+
+	// the number of samples to evaluate, which the sampler may lower
+	limit := &atomic.Int64{}
+	limit.Store(int64(numOfIterations))
+
+	refitter, canRefit := e.Model.(Refitter[T])
+
+	// receive samples of random points for numOfIterations
+	var samples <-chan []Point3D
+	var stopping stoppingSampler
+	if e.Sampler != nil {
+		sampler := e.Sampler(&pointCloud, e.Model.SampleSize(), numOfIterations)
+		samples = pointCloud.TakeNSamples(numOfIterations, e.Model.SampleSize(), sampler)
+		stopping, _ = sampler.(stoppingSampler)
+	} else {
+		samples = pointCloud.TakeNSamples(numOfIterations, e.Model.SampleSize())
+	}
+
+	// get primitive
+	primitive := getModelC(samples, e.Model, limit)
+
+	// get supporting points, dropping the hypotheses rejected early
+	var supportingPoints <-chan ModelWithSupport[T]
+	var verifier *sprtVerifier[T]
+	if e.EarlyRejection {
+		verifier = newSprtVerifier(&pointCloud, e.Model, eps)
+		supportingPoints = getVerifiedModelsC(primitive, verifier)
+	} else {
+		supportingPoints = GetModelSupportingPointsC(&pointCloud, primitive, e.Model, eps)
+	}
+
+	// each new best hypothesis is locally optimized, raises the bar of the early rejection,
+	// and may let the sampler end the run early
+	onBest := func(best ModelWithSupport[T]) ModelWithSupport[T] {
+		if e.LocalOptimization && canRefit {
+			best = e.localOptimization(best, &pointCloud, eps, refitter)
+		}
+		if verifier != nil {
+			verifier.update(best.SupportSize)
+		}
+		if stopping != nil {
+			stop := stopping.Update(func(index int) bool {
+				return e.Model.Residual(best.Model, &pointCloud.points[index]) <= eps
+			})
+			if int64(stop) < limit.Load() {
+				limit.Store(int64(stop))
+			}
+		}
+		return best
+	}
+
+	// get best primitive
+	best := <-modelFanIn(supportingPoints, onBest)
+	if verifier != nil && e.Stats != nil {
+		verifier.addStats(e.Stats)
+	}
+	if best.SupportSize < 0 {
+		return best, false
+	}
+
+	// refine the best primitive using all its supporting points, keeping it only if the support does not decrease
+	if e.Refit && canRefit && best.SupportSize > e.Model.SampleSize() {
+		if refitted, err := refitter.Refit(best.Model, best.SupportingPoints); err == nil {
+			if support := GetModelSupport(&pointCloud, e.Model, refitted, eps); support.SupportSize >= best.SupportSize {
+				best = support
+			}
+		}
+	}
+
+	return best, true
+}
+
+// method to retrieve given number of dominant primitives of the model from the point cloud
+// returns an array containing dominant primitives and the point cloud without the points supporting them
+// fewer primitives are returned if no valid primitive can be fitted to the remaining points
+func GetDominantModels[T any](numOfIterations int, pointCloud PointCloud, eps float64, model Model[T], numOfDominantModels ...int) ([]ModelWithSupport[T], PointCloud) {
+	return Engine[T]{Model: model}.GetDominantModels(numOfIterations, pointCloud, eps, numOfDominantModels...)
+}
+
+// method to retrieve given number of dominant primitives of the model from the point cloud
+// returns an array containing dominant primitives and the point cloud without the points supporting them
+// fewer primitives are returned if no valid primitive can be fitted to the remaining points
+func (e Engine[T]) GetDominantModels(numOfIterations int, pointCloud PointCloud, eps float64, numOfDominantModels ...int) ([]ModelWithSupport[T], PointCloud) {
+	// store the dominant primitives
+	dominantModels := []ModelWithSupport[T]{}
+	// if the number of dominant primitives is not specified, set it to the default value
+	if len(numOfDominantModels) == 0 {
+		numOfDominantModels = []int{DEFAULT_NUM_OF_DOMINANT_PLANES}
+	}
+	// store the point cloud
+	cloud := pointCloud
+	for i := 0; i < numOfDominantModels[0] && len(cloud.points) > e.Model.SampleSize(); i++ {
+		// identify the dominant primitive from given point cloud
+		dominantModel, ok := e.DominantModelIdentifier(numOfIterations, cloud, eps)
+		if !ok {
+			break
+		}
+		// remove the points on the dominant primitive from the point cloud
+		cloud = RemoveModel(&cloud, e.Model, dominantModel.Model, eps)
+		// bound the primitive to its supporting points
+		if shape, ok := any(&dominantModel.Model).(boundedShape); ok {
+			shape.SetExtent(dominantModel.SupportingPoints)
+		}
+		dominantModels = append(dominantModels, dominantModel)
+	}
+
+	return dominantModels, cloud
+}
+
+// creates a new point cloud in which all points within eps of the primitive have been removed
+func RemoveModel[T any](pointCloud *PointCloud, model Model[T], primitive T, eps float64) PointCloud {
+	return pointCloud.filter(func(i int) bool {
+		return model.Residual(primitive, &pointCloud.points[i]) > eps
+	})
+}
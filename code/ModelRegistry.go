@@ -0,0 +1,91 @@
+package code
+
+import (
+	"sort"
+)
+
+// DetectedModel is a dominant primitive of any model, with its supporting points
+type DetectedModel struct {
+	Model            any
+	SupportSize      int
+	SupportingPoints []Point3D
+}
+
+// ModelDetector runs the sequential extraction of the dominant primitives of a model
+type ModelDetector interface {
+	// number of points of a minimal sample
+	SampleSize() int
+	// retrieves given number of dominant primitives from the point cloud with the engine configured by options
+	// returns the dominant primitives and the point cloud without the points supporting them
+	Detect(numOfIterations int, pointCloud PointCloud, eps float64, numOfModels int, options EngineOptions) ([]DetectedModel, PointCloud)
+}
+
+// creates the detector of a model configured by the options of a run on given point cloud
+type ModelFactory func(options RansacOptions, pointCloud PointCloud) ModelDetector
+
+// registered models by name
+var modelFactories = map[string]ModelFactory{}
+
+// registers a model so that it can be selected by name as the shape of a run
+// registering a name again replaces the previous model
+func RegisterModel(name string, factory ModelFactory) {
+	modelFactories[name] = factory
+}
+
+// returns the factory of a registered model
+func GetModelFactory(name string) (ModelFactory, bool) {
+	factory, ok := modelFactories[name]
+	return factory, ok
+}
+
+// returns the names of the registered models in alphabetical order
+func RegisteredModels() []string {
+	names := []string{}
+	for name := range modelFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ModelDetector running the generic RANSAC engine on a model
+type modelDetector[T any] struct {
+	model Model[T]
+}
+
+// creates a ModelDetector for a model
+func NewModelDetector[T any](model Model[T]) ModelDetector {
+	return modelDetector[T]{model}
+}
+
+func (d modelDetector[T]) SampleSize() int {
+	return d.model.SampleSize()
+}
+
+func (d modelDetector[T]) Detect(numOfIterations int, pointCloud PointCloud, eps float64, numOfModels int, options EngineOptions) ([]DetectedModel, PointCloud) {
+	dominantModels, cloud := Engine[T]{d.model, options}.GetDominantModels(numOfIterations, pointCloud, eps, numOfModels)
+	detected := make([]DetectedModel, len(dominantModels))
+	for i, dominantModel := range dominantModels {
+		detected[i] = DetectedModel{dominantModel.Model, dominantModel.SupportSize, dominantModel.SupportingPoints}
+	}
+	return detected, cloud
+}
+
+// register the built in models
+func init() {
+	RegisterModel(SHAPE_PLANE, func(options RansacOptions, pointCloud PointCloud) ModelDetector {
+		return NewModelDetector[Plane3D](PlaneModel{options.Orientation})
+	})
+	RegisterModel(SHAPE_SPHERE, func(options RansacOptions, pointCloud PointCloud) ModelDetector {
+		return NewModelDetector[Sphere3D](SphereModel{options.MinRadius, options.MaxRadius})
+	})
+	RegisterModel(SHAPE_CYLINDER, func(options RansacOptions, pointCloud PointCloud) ModelDetector {
+		return NewModelDetector[Cylinder3D](NewCylinderModel(pointCloud, options.NormalNeighbours, options.MinRadius, options.MaxRadius, options.Axis, options.MaxAxisAngle))
+	})
+	RegisterModel(SHAPE_LINE, func(options RansacOptions, pointCloud PointCloud) ModelDetector {
+		return NewModelDetector[Line3D](LineModel{})
+	})
+	RegisterModel(SHAPE_CIRCLE, func(options RansacOptions, pointCloud PointCloud) ModelDetector {
+		return NewModelDetector[Circle3D](CircleModel{options.MinRadius, options.MaxRadius})
+	})
+}
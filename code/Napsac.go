@@ -0,0 +1,63 @@
+package code
+
+import (
+	"math/rand"
+)
+
+// default radius of the neighbourhood of NAPSAC samples, as a multiple of the distance threshold eps
+const DEFAULT_NAPSAC_RADIUS_FACTOR float64 = 10
+
+// number of random first points tried before a NAPSAC sample falls back to uniform sampling
+const NAPSAC_MAX_ATTEMPTS int = 10
+
+// NapsacSampler draws samples of neighbouring points (NAPSAC)
+// the first point of a sample is drawn uniformly and the others among its neighbours within a radius,
+// so that samples hit primitives covering a small part of the point cloud far more often than uniform samples
+type NapsacSampler struct {
+	points []Point3D
+	// spatial index of the points
+	tree   *KDTree
+	radius float64
+}
+
+// creates the NAPSAC sampler of the points drawing samples within given radius
+func NewNapsacSampler(points []Point3D, radius float64) *NapsacSampler {
+	return &NapsacSampler{
+		points: points,
+		tree:   NewKDTree(points),
+		radius: radius,
+	}
+}
+
+// creates the factory of NAPSAC samplers drawing samples within given radius
+func NapsacSamplerFactory(radius float64) SamplerFactory {
+	return func(pointCloud *PointCloud, sampleSize int, numOfIterations int) Sampler {
+		return NewNapsacSampler(pointCloud.points, radius)
+	}
+}
+
+// draws the next sample
+// first points with too few neighbours are drawn again, and the sample is drawn uniformly after NAPSAC_MAX_ATTEMPTS
+func (s *NapsacSampler) Sample(size int) []int {
+	for attempt := 0; attempt < NAPSAC_MAX_ATTEMPTS; attempt++ {
+		first := rand.Intn(len(s.points))
+		// the neighbourhood contains the first point itself
+		neighbours := s.tree.RadiusSearch(s.points[first], s.radius)
+		if len(neighbours) < size {
+			continue
+		}
+		position := 0
+		for i, neighbour := range neighbours {
+			if neighbour == first {
+				position = i
+				break
+			}
+		}
+		sample := []int{first}
+		for _, i := range distinctIndices(len(neighbours), size-1, rand.Intn, position) {
+			sample = append(sample, neighbours[i])
+		}
+		return sample
+	}
+	return distinctIndices(len(s.points), size, rand.Intn)
+}
@@ -0,0 +1,63 @@
+package code
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// number of points whose neighbourhoods are used to estimate the noise scale of a point cloud
+const NOISE_SAMPLE_SIZE int = 2000
+
+// default multiple of the estimated noise scale used as eps
+const DEFAULT_EPS_SIGMA_FACTOR float64 = 3
+
+// estimates the noise scale sigma of the point cloud from the k nearest neighbours of a random sample of points
+// the standard deviation of each neighbourhood from its least squares plane is corrected for the 3 parameters of the
+// plane, and the median over the sample discards the neighbourhoods straddling edges and corners
+func (pointCloud *PointCloud) EstimateNoise(k int) float64 {
+	if k <= 0 {
+		k = DEFAULT_NORMAL_NEIGHBOURS
+	}
+	if k > len(pointCloud.points) {
+		k = len(pointCloud.points)
+	}
+	if k <= 3 {
+		return 0
+	}
+	tree := NewKDTree(pointCloud.points)
+
+	deviations := []float64{}
+	neighbourhood := make([]Point3D, 0, k)
+	for _, index := range rand.Perm(len(pointCloud.points)) {
+		if len(deviations) == NOISE_SAMPLE_SIZE {
+			break
+		}
+		neighbourhood = neighbourhood[:0]
+		for _, neighbour := range tree.KNearest(pointCloud.points[index], k) {
+			neighbourhood = append(neighbourhood, pointCloud.points[neighbour])
+		}
+		// the smallest eigenvalue is the variance of the neighbourhood along the normal of its plane
+		_, values, _ := getPrincipalAxes(neighbourhood)
+		deviations = append(deviations, math.Sqrt(math.Max(values[0], 0)*float64(k)/float64(k-3)))
+	}
+
+	sort.Float64s(deviations)
+	return deviations[len(deviations)/2]
+}
+
+// returns factor times the noise scale sigma as eps
+// a zero sigma, estimated from too few points or from a noiseless point cloud, falls back to the given eps with a
+// warning, and is an error if the given eps is not positive
+func getNoiseEps(sigma, factor, eps float64) (float64, error) {
+	if sigma > 0 {
+		return factor * sigma, nil
+	}
+	if eps <= 0 {
+		return 0, errors.New("noise of the point cloud could not be estimated")
+	}
+	fmt.Println("Warning: noise of the point cloud could not be estimated, using eps: ", eps)
+	return eps, nil
+}
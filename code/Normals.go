@@ -0,0 +1,57 @@
+package code
+
+import (
+	"runtime"
+	"sync"
+)
+
+// default number of neighbours used to estimate the normal of a point
+const DEFAULT_NORMAL_NEIGHBOURS int = 30
+
+// estimates the unit normal and the surface curvature of every point from its k nearest neighbours
+// the curvature is the fraction of the neighbourhood variance along the normal (0 for a perfect plane)
+// the work is split between goroutines, one per CPU
+func (pointCloud *PointCloud) EstimateNormals(k int) ([]Point3D, []float64) {
+	if k <= 0 {
+		k = DEFAULT_NORMAL_NEIGHBOURS
+	}
+	normals := make([]Point3D, len(pointCloud.points))
+	curvatures := make([]float64, len(pointCloud.points))
+	tree := NewKDTree(pointCloud.points)
+
+	// each worker handles a contiguous range of points
+	workers := runtime.NumCPU()
+	chunk := (len(pointCloud.points) + workers - 1) / workers
+	var wg sync.WaitGroup
+	for start := 0; start < len(pointCloud.points); start += chunk {
+		end := start + chunk
+		if end > len(pointCloud.points) {
+			end = len(pointCloud.points)
+		}
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			neighbourhood := make([]Point3D, 0, k)
+			for i := start; i < end; i++ {
+				neighbourhood = neighbourhood[:0]
+				for _, index := range tree.KNearest(pointCloud.points[i], k) {
+					neighbourhood = append(neighbourhood, pointCloud.points[index])
+				}
+				normals[i], curvatures[i] = getNormalAndCurvature(neighbourhood)
+			}
+		}(start, end)
+	}
+	wg.Wait()
+
+	return normals, curvatures
+}
+
+// computes the unit normal and the curvature of a neighbourhood of points
+func getNormalAndCurvature(points []Point3D) (Point3D, float64) {
+	_, values, axes := getPrincipalAxes(points)
+	total := values[0] + values[1] + values[2]
+	if total <= 0 {
+		return axes[0], 0
+	}
+	return axes[0], values[0] / total
+}
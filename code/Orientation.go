@@ -0,0 +1,51 @@
+package code
+
+import (
+	"errors"
+	"math"
+)
+
+// default maximum angle in degrees between the normal of a plane and its constrained direction
+const DEFAULT_ORIENTATION_ANGLE float64 = 10
+
+// OrientationConstraint restricts the direction of the normals of detected planes
+// with the vertical axis (0, 0, 1), the normals of floors and ceilings are parallel to the axis and those of walls
+// perpendicular to it
+type OrientationConstraint struct {
+	// direction of the constraint (zero if none)
+	Axis Point3D
+	// the normal must be perpendicular to Axis instead of parallel to it
+	Perpendicular bool
+	// maximum angle in degrees between the normal and its constrained direction (0 uses DEFAULT_ORIENTATION_ANGLE)
+	MaxAngle float64
+}
+
+// reports whether the constraint restricts anything
+func (c OrientationConstraint) IsSet() bool {
+	return c.Axis.Norm() > 0
+}
+
+// reports whether a plane satisfies the constraint
+// normals have no sign, so that a normal opposite to Axis is parallel to it
+func (c OrientationConstraint) Accepts(plane Plane3D) bool {
+	if !c.IsSet() {
+		return true
+	}
+	maxAngle := c.MaxAngle
+	if maxAngle <= 0 {
+		maxAngle = DEFAULT_ORIENTATION_ANGLE
+	}
+	cosine := math.Abs(plane.GetUnitNormal().Dot(c.Axis.Normalize()))
+	if c.Perpendicular {
+		return cosine <= math.Sin(maxAngle*math.Pi/180)
+	}
+	return cosine >= math.Cos(maxAngle*math.Pi/180)
+}
+
+// returns an error if a plane violates the constraint
+func (c OrientationConstraint) validate(plane Plane3D) error {
+	if !c.Accepts(plane) {
+		return errors.New("plane normal out of orientation range")
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package code
+
+import (
+	"math"
+)
+
+// computes the centroid of a set of points
+func GetCentroid(points []Point3D) Point3D {
+	centroid := Point3D{}
+	if len(points) == 0 {
+		return centroid
+	}
+	for _, point := range points {
+		centroid = centroid.Add(point)
+	}
+	return centroid.Scale(1 / float64(len(points)))
+}
+
+// computes the centroid and the covariance matrix of a set of points
+func getCovariance(points []Point3D) (Point3D, [3][3]float64) {
+	centroid := GetCentroid(points)
+	covariance := [3][3]float64{}
+	if len(points) == 0 {
+		return centroid, covariance
+	}
+	for _, point := range points {
+		d := point.Sub(centroid)
+		v := [3]float64{d.X, d.Y, d.Z}
+		for i := 0; i < 3; i++ {
+			for j := i; j < 3; j++ {
+				covariance[i][j] += v[i] * v[j]
+			}
+		}
+	}
+	// normalize and fill the lower triangle
+	for i := 0; i < 3; i++ {
+		for j := i; j < 3; j++ {
+			covariance[i][j] /= float64(len(points))
+			covariance[j][i] = covariance[i][j]
+		}
+	}
+	return centroid, covariance
+}
+
+// computes the eigenvalues and unit eigenvectors of a symmetric 3x3 matrix using Jacobi rotations
+// eigenvalues are returned in increasing order, with the eigenvectors in the same order
+func eigenSymmetric3(m [3][3]float64) ([3]float64, [3]Point3D) {
+	a := m
+	// accumulated rotations, the columns are the eigenvectors
+	v := [3][3]float64{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+
+	for sweep := 0; sweep < 50; sweep++ {
+		// stop once the off diagonal elements vanish
+		off := a[0][1]*a[0][1] + a[0][2]*a[0][2] + a[1][2]*a[1][2]
+		if off < 1e-30 {
+			break
+		}
+		for p := 0; p < 2; p++ {
+			for q := p + 1; q < 3; q++ {
+				if a[p][q] == 0 {
+					continue
+				}
+				// rotation angle zeroing a[p][q]
+				theta := (a[q][q] - a[p][p]) / (2 * a[p][q])
+				t := 1 / (math.Abs(theta) + math.Sqrt(theta*theta+1))
+				if theta < 0 {
+					t = -t
+				}
+				c := 1 / math.Sqrt(t*t+1)
+				s := t * c
+				// apply the rotation to rows and columns p and q
+				for k := 0; k < 3; k++ {
+					akp, akq := a[k][p], a[k][q]
+					a[k][p] = c*akp - s*akq
+					a[k][q] = s*akp + c*akq
+				}
+				for k := 0; k < 3; k++ {
+					apk, aqk := a[p][k], a[q][k]
+					a[p][k] = c*apk - s*aqk
+					a[q][k] = s*apk + c*aqk
+				}
+				for k := 0; k < 3; k++ {
+					vkp, vkq := v[k][p], v[k][q]
+					v[k][p] = c*vkp - s*vkq
+					v[k][q] = s*vkp + c*vkq
+				}
+			}
+		}
+	}
+
+	values := [3]float64{a[0][0], a[1][1], a[2][2]}
+	vectors := [3]Point3D{}
+	for i := 0; i < 3; i++ {
+		vectors[i] = Point3D{v[0][i], v[1][i], v[2][i]}
+	}
+
+	// sort by increasing eigenvalue
+	for i := 0; i < 2; i++ {
+		for j := i + 1; j < 3; j++ {
+			if values[j] < values[i] {
+				values[i], values[j] = values[j], values[i]
+				vectors[i], vectors[j] = vectors[j], vectors[i]
+			}
+		}
+	}
+	return values, vectors
+}
+
+// computes the principal axes of a set of points
+// returns the centroid, the variances along the axes in increasing order and the axes in the same order
+func getPrincipalAxes(points []Point3D) (Point3D, [3]float64, [3]Point3D) {
+	centroid, covariance := getCovariance(points)
+	values, vectors := eigenSymmetric3(covariance)
+	return centroid, values, vectors
+}
@@ -18,6 +18,8 @@ type Plane3DwSupport struct {
 	Plane3D
  	SupportSize int
 	SupportingPoints []Point3D
+	// metrics of the surface covered by the supporting points, set by SetMetrics (nil if not computed)
+	Metrics *PlaneMetrics
 }
 
 // computes the plane defined by a set of 3 points
@@ -33,24 +35,20 @@ func GetPlane(p1, p2, p3 Point3D) Plane3D {
 }
 
 // received array containing 3 Point3D objects and sends back a Plane3D object through output channel
+// collinear points, which define no plane, are dropped
 func GetPlaneC(pointsIn <-chan [3]Point3D) <-chan Plane3D {
-	dprint("********** GetPlaneC started **********")
-	// outbound channel
-	planeOut := make(chan Plane3D)
-	// goroutine to compute the plane
-	go func() {
-		defer close(planeOut)
-		defer dprint("********** GetPlaneC done **********")
-		// until we have points coming in on the inbound channel
-		for points := range pointsIn {
-			// get array of points from inbound channel
-			// compute the plane
-			// send plane on outbound channel
-			planeOut <- GetPlane(points[0], points[1], points[2])
-		}
-	}()
-	// return the outbound channel
-	return planeOut
+	samples := convertC(pointsIn, func(points [3]Point3D) []Point3D {
+		return points[:]
+	})
+	return GetModelC[Plane3D](samples, PlaneModel{})
+}
+
+// computes the least squares plane of a set of points
+// the normal is the direction of least variance of the points, and the plane passes through their centroid
+func FitPlane(points []Point3D) Plane3D {
+	centroid, _, axes := getPrincipalAxes(points)
+	normal := axes[0]
+	return Plane3D{normal.X, normal.Y, normal.Z, -normal.Dot(centroid)}
 }
 
 func GetNormal(point3D1, point3D2, point3D3 Point3D) Point3D {
@@ -65,6 +63,11 @@ func GetNormal(point3D1, point3D2, point3D3 Point3D) Point3D {
 	return normal
 }
 
+// unit normal of a plane
+func (p *Plane3D) GetUnitNormal() Point3D {
+	return Point3D{p.A, p.B, p.C}.Normalize()
+}
+
 // calculate distance of a point to a plane
 func (p *Plane3D) GetDistance(point *Point3D) float64 {
 	return math.Abs(p.A*point.X + p.B*point.Y + p.C*point.Z + p.D) / math.Sqrt(p.A*p.A+p.B*p.B+p.C*p.C)
@@ -107,4 +110,82 @@ func GetSupportingPoints(p *Plane3D, points []Point3D, eps float64) int {
 
 	// return the array of supporting points
 	return len(supportingPoints)
-}
\ No newline at end of file
+}
+
+// reports whether 3 points lie on a line
+func isCollinear(p1, p2, p3 Point3D) bool {
+	a := p2.Sub(p1)
+	b := p3.Sub(p1)
+	cross := a.Cross(b)
+	return cross.Dot(cross) <= 1e-12*a.Dot(a)*b.Dot(b)
+}
+
+// PlaneModel is the Model of planes, fitted to samples of 3 points
+// planes violating the orientation constraint are rejected before their support is counted
+type PlaneModel struct {
+	Orientation OrientationConstraint
+}
+
+func (m PlaneModel) SampleSize() int {
+	return 3
+}
+
+func (m PlaneModel) IsDegenerate(sample []Point3D) bool {
+	return isCollinear(sample[0], sample[1], sample[2])
+}
+
+func (m PlaneModel) Fit(sample []Point3D) (Plane3D, error) {
+	plane := GetPlane(sample[0], sample[1], sample[2])
+	return plane, m.Orientation.validate(plane)
+}
+
+func (m PlaneModel) Residual(plane Plane3D, point *Point3D) float64 {
+	return plane.GetDistance(point)
+}
+
+func (m PlaneModel) Refit(plane Plane3D, points []Point3D) (Plane3D, error) {
+	refitted := FitPlane(points)
+	return refitted, m.Orientation.validate(refitted)
+}
+
+// PlaneFrame is an orthonormal 2D coordinate frame lying in a plane
+type PlaneFrame struct {
+	// point of the plane closest to the origin
+	Origin Point3D
+	// in-plane axes
+	U Point3D
+	V Point3D
+	// unit normal of the plane
+	Normal Point3D
+}
+
+// computes a coordinate frame in the plane
+func (p *Plane3D) GetFrame() PlaneFrame {
+	// unit normal and signed distance of the plane from the origin
+	length := math.Sqrt(p.A*p.A + p.B*p.B + p.C*p.C)
+	normal := Point3D{p.A / length, p.B / length, p.C / length}
+	origin := normal.Scale(-p.D / length)
+
+	// use the coordinate axis least aligned with the normal to build the first in-plane axis
+	axis := Point3D{1, 0, 0}
+	if math.Abs(normal.Y) < math.Abs(normal.X) && math.Abs(normal.Y) <= math.Abs(normal.Z) {
+		axis = Point3D{0, 1, 0}
+	} else if math.Abs(normal.Z) < math.Abs(normal.X) {
+		axis = Point3D{0, 0, 1}
+	}
+	u := normal.Cross(axis).Normalize()
+	v := normal.Cross(u)
+
+	return PlaneFrame{origin, u, v, normal}
+}
+
+// projects a point onto the frame and returns its 2D coordinates
+func (f *PlaneFrame) ToPlane(point Point3D) (float64, float64) {
+	d := point.Sub(f.Origin)
+	return d.Dot(f.U), d.Dot(f.V)
+}
+
+// returns the 3D point at given 2D coordinates of the frame
+func (f *PlaneFrame) FromPlane(x, y float64) Point3D {
+	return f.Origin.Add(f.U.Scale(x).Add(f.V.Scale(y)))
+}
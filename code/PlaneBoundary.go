@@ -0,0 +1,63 @@
+package code
+
+import "math"
+
+// the points of a plane are thinned to one point per cell of size alpha / BOUNDARY_CELLS_PER_ALPHA before computing
+// its concave hull, which keeps the triangulation small without changing the shape at the scale of alpha
+const BOUNDARY_CELLS_PER_ALPHA float64 = 4
+
+// PlaneBoundary is the outline of the supporting points of a plane, as polygons of 3D points lying in the plane
+type PlaneBoundary struct {
+	// vertices of the convex hull, counterclockwise around the normal of the plane
+	ConvexHull []Point3D `json:"convex_hull"`
+	// boundary loops of the alpha shape, the largest first, the outer boundaries being counterclockwise around the
+	// normal of the plane and the holes clockwise
+	ConcaveHull [][]Point3D `json:"concave_hull,omitempty"`
+}
+
+// computes the boundary of the supporting points of the plane, projected into the plane frame
+// the concave hull is the alpha shape of the points, made of the Delaunay triangles whose circumradius is at most
+// alpha, so that gaps wider than about 2 alpha are outside of it; it is not computed if alpha is not positive
+func (plane *Plane3DwSupport) GetBoundary(alpha float64) PlaneBoundary {
+	frame := plane.GetFrame()
+	points := frame.ToPlane2D(plane.SupportingPoints)
+	boundary := PlaneBoundary{ConvexHull: frame.FromPlane2D(ConvexHull2D(points))}
+	if alpha <= 0 {
+		return boundary
+	}
+
+	for _, loop := range AlphaShape2D(thinPoints2D(points, alpha/BOUNDARY_CELLS_PER_ALPHA), alpha) {
+		boundary.ConcaveHull = append(boundary.ConcaveHull, frame.FromPlane2D(loop))
+	}
+	return boundary
+}
+
+// returns the polygon outlining the plane, the largest loop of the concave hull if computed, else the convex hull
+func (b PlaneBoundary) getOutline() []Point3D {
+	if len(b.ConcaveHull) > 0 {
+		return b.ConcaveHull[0]
+	}
+	return b.ConvexHull
+}
+
+// returns the centroid of the points falling in each cell of a grid of given cell size
+func thinPoints2D(points []Point2D, cellSize float64) []Point2D {
+	sums := map[gridCell]Point2D{}
+	counts := map[gridCell]int{}
+	cells := []gridCell{}
+	for _, point := range points {
+		cell := gridCell{int(math.Floor(point.X / cellSize)), int(math.Floor(point.Y / cellSize))}
+		if counts[cell] == 0 {
+			cells = append(cells, cell)
+		}
+		sum := sums[cell]
+		sums[cell] = Point2D{sum.X + point.X, sum.Y + point.Y}
+		counts[cell]++
+	}
+	thinned := make([]Point2D, len(cells))
+	for i, cell := range cells {
+		count := float64(counts[cell])
+		thinned[i] = Point2D{sums[cell].X / count, sums[cell].Y / count}
+	}
+	return thinned
+}
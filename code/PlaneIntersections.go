@@ -0,0 +1,155 @@
+package code
+
+import (
+	"errors"
+	"math"
+)
+
+// default minimum angle in degrees between intersected planes, below which their intersection is ill-conditioned
+const DEFAULT_INTERSECTION_ANGLE float64 = 10
+
+// PlaneEdge is the segment of the intersection line of 2 planes, numbered from 1, where both planes have points
+type PlaneEdge struct {
+	First   int    `json:"first"`
+	Second  int    `json:"second"`
+	Segment Line3D `json:"segment"`
+}
+
+// PlaneCorner is the intersection point of 3 planes, numbered from 1, sharing edges
+type PlaneCorner struct {
+	Planes [3]int  `json:"planes"`
+	Point  Point3D `json:"point"`
+}
+
+// returns the unit normal of the plane and the signed distance h from the origin such that normal . x = h in the plane
+func (p *Plane3D) getHessianForm() (Point3D, float64) {
+	length := Point3D{p.A, p.B, p.C}.Norm()
+	return p.GetUnitNormal(), -p.D / length
+}
+
+// computes the intersection line of 2 planes, passing through its point closest to the origin
+// returns an error if the planes are within minAngle degrees of being parallel (0 uses DEFAULT_INTERSECTION_ANGLE)
+func (p *Plane3D) IntersectPlane(other Plane3D, minAngle float64) (Line3D, error) {
+	if minAngle <= 0 {
+		minAngle = DEFAULT_INTERSECTION_ANGLE
+	}
+	n1, h1 := p.getHessianForm()
+	n2, h2 := other.getHessianForm()
+	direction := n1.Cross(n2)
+	// the norm of the cross product of the unit normals is the sine of the angle between the planes
+	sine := direction.Norm()
+	if sine < math.Sin(minAngle*math.Pi/180) {
+		return Line3D{}, errors.New("planes are nearly parallel")
+	}
+	cosine := n1.Dot(n2)
+	c1 := (h1 - h2*cosine) / (sine * sine)
+	c2 := (h2 - h1*cosine) / (sine * sine)
+	return Line3D{Point: n1.Scale(c1).Add(n2.Scale(c2)), Direction: direction.Scale(1 / sine)}, nil
+}
+
+// computes the intersection point of 3 planes
+// returns an error if 2 of the planes are within minAngle degrees of being parallel (0 uses
+// DEFAULT_INTERSECTION_ANGLE), or if the normals are within minAngle degrees of being coplanar, in which case the
+// planes meet in a line or not at all
+func IntersectPlanes(p1, p2, p3 Plane3D, minAngle float64) (Point3D, error) {
+	if minAngle <= 0 {
+		minAngle = DEFAULT_INTERSECTION_ANGLE
+	}
+	minSine := math.Sin(minAngle * math.Pi / 180)
+	n1, h1 := p1.getHessianForm()
+	n2, h2 := p2.getHessianForm()
+	n3, h3 := p3.getHessianForm()
+	c23, c31, c12 := n2.Cross(n3), n3.Cross(n1), n1.Cross(n2)
+	if c23.Norm() < minSine || c31.Norm() < minSine || c12.Norm() < minSine {
+		return Point3D{}, errors.New("planes are nearly parallel")
+	}
+	// the determinant is the sine of the angle between the third normal and the plane of the other two, times the
+	// sine of the angle between these
+	det := n1.Dot(c23)
+	if math.Abs(det) < minSine*c23.Norm() {
+		return Point3D{}, errors.New("plane normals are nearly coplanar")
+	}
+	return c23.Scale(h1).Add(c31.Scale(h2)).Add(c12.Scale(h3)).Scale(1 / det), nil
+}
+
+// computes the edges between the planes, the segments of their pairwise intersection lines along which both planes
+// have supporting points within maxDistance of the line, and the corners where 3 planes sharing edges meet within
+// maxDistance of the edges
+// pairs of planes within minAngle degrees of being parallel (0 uses DEFAULT_INTERSECTION_ANGLE) have no edge
+func GetPlaneEdges(planes []Plane3DwSupport, maxDistance, minAngle float64) ([]PlaneEdge, []PlaneCorner) {
+	edges := []PlaneEdge{}
+	edgeIndex := map[[2]int]int{}
+	for i := range planes {
+		for j := i + 1; j < len(planes); j++ {
+			line, err := planes[i].IntersectPlane(planes[j].Plane3D, minAngle)
+			if err != nil {
+				continue
+			}
+			// overlap of the extents of the points of both planes along the line
+			low1, high1 := getLineExtent(line, planes[i].SupportingPoints, maxDistance)
+			low2, high2 := getLineExtent(line, planes[j].SupportingPoints, maxDistance)
+			low, high := math.Max(low1, low2), math.Min(high1, high2)
+			if low >= high {
+				continue
+			}
+			line.Point = line.Point.Add(line.Direction.Scale(low))
+			line.Length = high - low
+			edgeIndex[[2]int{i, j}] = len(edges)
+			edges = append(edges, PlaneEdge{First: i + 1, Second: j + 1, Segment: line})
+		}
+	}
+
+	// corners of the triples of planes sharing 3 edges
+	corners := []PlaneCorner{}
+	for i := range planes {
+		for j := i + 1; j < len(planes); j++ {
+			for k := j + 1; k < len(planes); k++ {
+				triple := [][2]int{{i, j}, {i, k}, {j, k}}
+				shared := true
+				for _, pair := range triple {
+					if _, ok := edgeIndex[pair]; !ok {
+						shared = false
+					}
+				}
+				if !shared {
+					continue
+				}
+				point, err := IntersectPlanes(planes[i].Plane3D, planes[j].Plane3D, planes[k].Plane3D, minAngle)
+				if err != nil {
+					continue
+				}
+				near := true
+				for _, pair := range triple {
+					if getSegmentDistance(edges[edgeIndex[pair]].Segment, point) > maxDistance {
+						near = false
+					}
+				}
+				if near {
+					corners = append(corners, PlaneCorner{Planes: [3]int{i + 1, j + 1, k + 1}, Point: point})
+				}
+			}
+		}
+	}
+
+	return edges, corners
+}
+
+// returns the range of the positions along the line of the points within maxDistance of it, empty if none
+func getLineExtent(line Line3D, points []Point3D, maxDistance float64) (float64, float64) {
+	low, high := math.Inf(1), math.Inf(-1)
+	for i := range points {
+		if line.GetDistance(&points[i]) > maxDistance {
+			continue
+		}
+		t := points[i].Sub(line.Point).Dot(line.Direction)
+		low = math.Min(low, t)
+		high = math.Max(high, t)
+	}
+	return low, high
+}
+
+// computes the distance of a point to a segment
+func getSegmentDistance(segment Line3D, point Point3D) float64 {
+	t := math.Max(0, math.Min(segment.Length, point.Sub(segment.Point).Dot(segment.Direction)))
+	return point.Sub(segment.Point.Add(segment.Direction.Scale(t))).Norm()
+}
@@ -0,0 +1,95 @@
+package code
+
+import (
+	"math"
+	"sort"
+)
+
+// default maximum angle in degrees between the normals of planes merged as the same surface
+const DEFAULT_MERGE_ANGLE float64 = 5
+
+// PlaneMerge records the planes, numbered from 1 in the order they were detected, merged into a plane numbered from 1
+// in the order of the merged planes
+type PlaneMerge struct {
+	Plane  int   `json:"plane"`
+	Merged []int `json:"merged"`
+}
+
+// merges the planes which are the same surface, whose normals are within maxAngle degrees (0 uses DEFAULT_MERGE_ANGLE)
+// and whose centroids are within maxOffset of each other's plane
+// planes are merged transitively, each merged plane being refitted to the points of its planes
+// returns the planes, the largest first, and the merges of more than one plane
+func MergePlanes(planes []Plane3DwSupport, maxAngle, maxOffset float64) ([]Plane3DwSupport, []PlaneMerge) {
+	if maxAngle <= 0 {
+		maxAngle = DEFAULT_MERGE_ANGLE
+	}
+
+	// group the planes which are the same surface
+	centroids := make([]Point3D, len(planes))
+	for i, plane := range planes {
+		centroids[i] = GetCentroid(plane.SupportingPoints)
+	}
+	groups := newUnionFind(len(planes))
+	for i := range planes {
+		for j := i + 1; j < len(planes); j++ {
+			if getPlaneAngle(planes[i].Plane3D, planes[j].Plane3D) > maxAngle {
+				continue
+			}
+			offset := math.Max(planes[i].GetDistance(&centroids[j]), planes[j].GetDistance(&centroids[i]))
+			if offset <= maxOffset {
+				groups.union(i, j)
+			}
+		}
+	}
+
+	// planes of each group, in the order they were detected
+	members := map[int][]int{}
+	order := []int{}
+	for i := range planes {
+		group := groups.find(i)
+		if _, ok := members[group]; !ok {
+			order = append(order, group)
+		}
+		members[group] = append(members[group], i)
+	}
+
+	// merge the planes of each group
+	merged := make([]Plane3DwSupport, len(order))
+	for k, group := range order {
+		if len(members[group]) == 1 {
+			merged[k] = planes[members[group][0]]
+			continue
+		}
+		points := []Point3D{}
+		for _, i := range members[group] {
+			points = append(points, planes[i].SupportingPoints...)
+		}
+		merged[k] = Plane3DwSupport{Plane3D: FitPlane(points), SupportSize: len(points), SupportingPoints: points}
+	}
+
+	// largest planes first, then renumber the merges
+	positions := make([]int, len(order))
+	for k := range positions {
+		positions[k] = k
+	}
+	sort.SliceStable(positions, func(a, b int) bool {
+		return merged[positions[a]].SupportSize > merged[positions[b]].SupportSize
+	})
+	result := make([]Plane3DwSupport, len(order))
+	merges := []PlaneMerge{}
+	for position, k := range positions {
+		result[position] = merged[k]
+		if len(members[order[k]]) > 1 {
+			merge := PlaneMerge{Plane: position + 1}
+			for _, i := range members[order[k]] {
+				merge.Merged = append(merge.Merged, i+1)
+			}
+			merges = append(merges, merge)
+		}
+	}
+	sort.Slice(merges, func(a, b int) bool {
+		return merges[a].Plane < merges[b].Plane
+	})
+
+	return result, merges
+}
@@ -0,0 +1,129 @@
+package code
+
+import "math"
+
+// OrientedRectangle is a rectangle lying in a plane
+type OrientedRectangle struct {
+	Center Point3D `json:"center"`
+	// corners, counterclockwise around the normal of the plane
+	Corners [4]Point3D `json:"corners"`
+	// length of the short and long sides
+	Width  float64 `json:"width"`
+	Length float64 `json:"length"`
+	// unit direction of the long sides
+	LengthAxis Point3D `json:"length_axis"`
+	// angle in degrees from the first axis of the plane frame to the long sides, between -90 and 90
+	Orientation float64 `json:"orientation"`
+}
+
+// PlaneMetrics quantifies the surface covered by the supporting points of a plane
+type PlaneMetrics struct {
+	Centroid Point3D `json:"centroid"`
+	// area covered by the points, from an occupancy grid in the plane or from the convex hull
+	Area float64 `json:"area"`
+	// area of the convex hull of the points
+	HullArea float64 `json:"hull_area"`
+	// minimum area rectangle containing the points
+	Rectangle OrientedRectangle `json:"rectangle"`
+	// number of points per unit of area
+	Density float64 `json:"density"`
+}
+
+// computes the metrics of the surface covered by the supporting points of the plane, projected into the plane frame
+// the area is the area of the cells of size cellSize holding points, or the area of the convex hull if cellSize is not
+// positive; the grid does not count the gaps between the points, but underestimates sparse surfaces
+func (plane *Plane3DwSupport) GetMetrics(cellSize float64) PlaneMetrics {
+	frame := plane.GetFrame()
+	points := frame.ToPlane2D(plane.SupportingPoints)
+	hull := ConvexHull2D(points)
+	metrics := PlaneMetrics{
+		Centroid:  GetCentroid(plane.SupportingPoints),
+		HullArea:  math.Abs(PolygonArea2D(hull)),
+		Rectangle: getMinimumAreaRectangle(frame, hull),
+	}
+
+	metrics.Area = metrics.HullArea
+	if cellSize > 0 {
+		cells := map[gridCell]bool{}
+		for _, point := range points {
+			cells[gridCell{int(math.Floor(point.X / cellSize)), int(math.Floor(point.Y / cellSize))}] = true
+		}
+		metrics.Area = float64(len(cells)) * cellSize * cellSize
+	}
+	if metrics.Area > 0 {
+		metrics.Density = float64(len(points)) / metrics.Area
+	}
+	return metrics
+}
+
+// computes the metrics of the plane with GetMetrics and stores them in the plane
+// the metrics are not updated if the supporting points change afterwards
+func (plane *Plane3DwSupport) SetMetrics(cellSize float64) PlaneMetrics {
+	metrics := plane.GetMetrics(cellSize)
+	plane.Metrics = &metrics
+	return metrics
+}
+
+// computes the minimum area rectangle containing a convex polygon of the plane frame with rotating calipers
+// one of the sides of the minimum area rectangle is collinear with an edge of the polygon
+func getMinimumAreaRectangle(frame PlaneFrame, hull []Point2D) OrientedRectangle {
+	if len(hull) == 0 {
+		return OrientedRectangle{}
+	}
+
+	// bounds of the polygon along the edge direction (cos, sin) and its normal for the smallest rectangle
+	bestArea := math.Inf(1)
+	var cos, sin, minU, maxU, minV, maxV float64
+	for i := range hull {
+		next := hull[(i+1)%len(hull)]
+		dx, dy := next.X-hull[i].X, next.Y-hull[i].Y
+		length := math.Hypot(dx, dy)
+		if length == 0 && len(hull) > 1 {
+			continue
+		}
+		c, s := 1.0, 0.0
+		if length > 0 {
+			c, s = dx/length, dy/length
+		}
+		u0, u1, v0, v1 := math.Inf(1), math.Inf(-1), math.Inf(1), math.Inf(-1)
+		for _, point := range hull {
+			u, v := point.X*c+point.Y*s, -point.X*s+point.Y*c
+			u0, u1 = math.Min(u0, u), math.Max(u1, u)
+			v0, v1 = math.Min(v0, v), math.Max(v1, v)
+		}
+		if area := (u1 - u0) * (v1 - v0); area < bestArea {
+			bestArea = area
+			cos, sin, minU, maxU, minV, maxV = c, s, u0, u1, v0, v1
+		}
+	}
+
+	// corners back in the plane frame, then in 3D
+	toFrame := func(u, v float64) Point2D {
+		return Point2D{u*cos - v*sin, u*sin + v*cos}
+	}
+	corners := []Point2D{toFrame(minU, minV), toFrame(maxU, minV), toFrame(maxU, maxV), toFrame(minU, maxV)}
+	center := toFrame((minU+maxU)/2, (minV+maxV)/2)
+	rectangle := OrientedRectangle{
+		Center: frame.FromPlane(center.X, center.Y),
+		Width:  maxV - minV,
+		Length: maxU - minU,
+	}
+	copy(rectangle.Corners[:], frame.FromPlane2D(corners))
+
+	// the long sides are along the edge direction unless the rectangle is wider than long
+	angle := math.Atan2(sin, cos)
+	if rectangle.Width > rectangle.Length {
+		rectangle.Width, rectangle.Length = rectangle.Length, rectangle.Width
+		angle += math.Pi / 2
+	}
+	// a direction and its opposite are the same orientation
+	for angle > math.Pi/2 {
+		angle -= math.Pi
+	}
+	for angle <= -math.Pi/2 {
+		angle += math.Pi
+	}
+	rectangle.Orientation = angle * 180 / math.Pi
+	rectangle.LengthAxis = frame.U.Scale(math.Cos(angle)).Add(frame.V.Scale(math.Sin(angle)))
+	return rectangle
+}
@@ -0,0 +1,340 @@
+package code
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// relationships between planes
+const (
+	RELATION_PARALLEL   = "parallel"
+	RELATION_ORTHOGONAL = "orthogonal"
+	// parallel planes at a fixed distance apart
+	RELATION_DISTANCE = "distance"
+)
+
+// number of sweeps of the joint refinement of the normals
+const RELATION_SWEEPS int = 10
+
+// PlaneRelation is a relationship between 2 planes, numbered from 1 in the order of the detected planes, which is
+// the order of the output files unless the planes are split into segments
+type PlaneRelation struct {
+	Kind   string `json:"kind"`
+	First  int    `json:"first"`
+	Second int    `json:"second"`
+	// distance between the planes of a RELATION_DISTANCE relationship
+	Distance float64 `json:"distance,omitempty"`
+}
+
+// string representation of a PlaneRelation
+func (r PlaneRelation) String() string {
+	if r.Kind == RELATION_DISTANCE {
+		return fmt.Sprintf("planes %d and %d %s %f", r.First, r.Second, r.Kind, r.Distance)
+	}
+	return fmt.Sprintf("planes %d and %d %s", r.First, r.Second, r.Kind)
+}
+
+// detects the pairs of planes which are parallel or orthogonal within maxAngle degrees
+func DetectPlaneRelations(planes []Plane3D, maxAngle float64) []PlaneRelation {
+	relations := []PlaneRelation{}
+	for i := range planes {
+		for j := i + 1; j < len(planes); j++ {
+			angle := getPlaneAngle(planes[i], planes[j])
+			if angle <= maxAngle {
+				relations = append(relations, PlaneRelation{Kind: RELATION_PARALLEL, First: i + 1, Second: j + 1})
+			} else if angle >= 90-maxAngle {
+				relations = append(relations, PlaneRelation{Kind: RELATION_ORTHOGONAL, First: i + 1, Second: j + 1})
+			}
+		}
+	}
+	return relations
+}
+
+// removes the relationships of the same kind between the same pair of planes as an earlier one, whatever the order
+// of the planes in the pair
+func uniqueRelations(relations []PlaneRelation) []PlaneRelation {
+	unique := []PlaneRelation{}
+	seen := map[PlaneRelation]bool{}
+	for _, relation := range relations {
+		key := PlaneRelation{Kind: relation.Kind, First: relation.First, Second: relation.Second}
+		if key.First > key.Second {
+			key.First, key.Second = key.Second, key.First
+		}
+		if !seen[key] {
+			seen[key] = true
+			unique = append(unique, relation)
+		}
+	}
+	return unique
+}
+
+// angle in degrees between 2 planes, from 0 (parallel) to 90 (orthogonal)
+func getPlaneAngle(p1, p2 Plane3D) float64 {
+	cosine := math.Min(math.Abs(p1.GetUnitNormal().Dot(p2.GetUnitNormal())), 1)
+	return math.Acos(cosine) * 180 / math.Pi
+}
+
+// distance between 2 nearly parallel planes, measured along the normal of the first one at the origin
+func getPlaneOffset(p1, p2 Plane3D) float64 {
+	n1, n2 := p1.GetUnitNormal(), p2.GetUnitNormal()
+	d1 := p1.D / Point3D{p1.A, p1.B, p1.C}.Norm()
+	d2 := p2.D / Point3D{p2.A, p2.B, p2.C}.Norm()
+	if n1.Dot(n2) < 0 {
+		d2 = -d2
+	}
+	return math.Abs(d1 - d2)
+}
+
+// jointly re-estimates the planes from their supporting points under the relations by constrained least squares
+// parallel planes, and planes at a fixed distance apart, share a normal fitted to all their points, and the normals
+// are kept orthogonal to the normals of the orthogonal planes; the offsets are then fitted under the distances
+// returns the refined planes, in the same order, or an error if the relations are invalid or contradictory
+func RefinePlanes(planes []Plane3DwSupport, relations []PlaneRelation) ([]Plane3D, error) {
+	for _, relation := range relations {
+		if relation.First < 1 || relation.First > len(planes) || relation.Second < 1 || relation.Second > len(planes) || relation.First == relation.Second {
+			return nil, fmt.Errorf("invalid planes in relation: %v", relation)
+		}
+		if relation.Kind != RELATION_PARALLEL && relation.Kind != RELATION_ORTHOGONAL && relation.Kind != RELATION_DISTANCE {
+			return nil, fmt.Errorf("unknown relation: %s", relation.Kind)
+		}
+	}
+
+	// groups of planes sharing a normal
+	groups := newUnionFind(len(planes))
+	for _, relation := range relations {
+		if relation.Kind != RELATION_ORTHOGONAL {
+			groups.union(relation.First-1, relation.Second-1)
+		}
+	}
+
+	// scatter matrices of the supporting points around their centroids, summed over each group
+	centroids := make([]Point3D, len(planes))
+	scatters := map[int][3][3]float64{}
+	support := map[int]int{}
+	largest := map[int]int{}
+	normals := map[int]Point3D{}
+	for i, plane := range planes {
+		group := groups.find(i)
+		centroid, covariance := getCovariance(plane.SupportingPoints)
+		centroids[i] = centroid
+		scatter := scatters[group]
+		for a := 0; a < 3; a++ {
+			for b := 0; b < 3; b++ {
+				scatter[a][b] += covariance[a][b] * float64(len(plane.SupportingPoints))
+			}
+		}
+		scatters[group] = scatter
+		// the initial normal of a group is the normal of its largest plane
+		if _, ok := normals[group]; !ok || plane.SupportSize > largest[group] {
+			normals[group] = plane.GetUnitNormal()
+			largest[group] = plane.SupportSize
+		}
+		support[group] += plane.SupportSize
+	}
+
+	// orthogonal groups of each group
+	orthogonal := map[int][]int{}
+	for _, relation := range relations {
+		if relation.Kind != RELATION_ORTHOGONAL {
+			continue
+		}
+		g1, g2 := groups.find(relation.First-1), groups.find(relation.Second-1)
+		if g1 == g2 {
+			return nil, fmt.Errorf("planes %d and %d cannot be both parallel and orthogonal", relation.First, relation.Second)
+		}
+		orthogonal[g1] = append(orthogonal[g1], g2)
+		orthogonal[g2] = append(orthogonal[g2], g1)
+	}
+
+	// refine the normals of the groups one at a time, the largest groups first, given the normals of the others
+	order := []int{}
+	for group := range normals {
+		order = append(order, group)
+	}
+	sort.Slice(order, func(i, j int) bool {
+		if support[order[i]] != support[order[j]] {
+			return support[order[i]] > support[order[j]]
+		}
+		return order[i] < order[j]
+	})
+	for sweep := 0; sweep < RELATION_SWEEPS; sweep++ {
+		for _, group := range order {
+			constraints := []Point3D{}
+			for _, other := range orthogonal[group] {
+				constraints = append(constraints, normals[other])
+			}
+			normal, err := getConstrainedNormal(scatters[group], constraints)
+			if err != nil {
+				return nil, err
+			}
+			// keep the orientation of the normal
+			if normal.Dot(normals[group]) < 0 {
+				normal = normal.Scale(-1)
+			}
+			normals[group] = normal
+		}
+	}
+
+	// offsets fitted to the centroids, then tied by the distances
+	offsets := make([]float64, len(planes))
+	for i := range planes {
+		offsets[i] = -normals[groups.find(i)].Dot(centroids[i])
+	}
+	offsets = getConstrainedOffsets(planes, offsets, relations)
+
+	refined := make([]Plane3D, len(planes))
+	for i := range planes {
+		normal := normals[groups.find(i)]
+		refined[i] = Plane3D{normal.X, normal.Y, normal.Z, offsets[i]}
+	}
+	return refined, nil
+}
+
+// computes the unit normal minimizing n^T scatter n among the normals orthogonal to the constraints
+// returns an error if the constraints leave no such normal
+func getConstrainedNormal(scatter [3][3]float64, constraints []Point3D) (Point3D, error) {
+	// independent constraint directions
+	directions := []Point3D{}
+	for _, constraint := range constraints {
+		if len(directions) == 1 && directions[0].Cross(constraint).Norm() < 1e-6 {
+			continue
+		}
+		if len(directions) == 2 {
+			if math.Abs(directions[0].Cross(directions[1]).Normalize().Dot(constraint)) > 1e-6 {
+				return Point3D{}, errors.New("planes cannot be orthogonal to 3 independent directions")
+			}
+			continue
+		}
+		directions = append(directions, constraint.Normalize())
+	}
+
+	switch len(directions) {
+	case 0:
+		_, axes := eigenSymmetric3(scatter)
+		return axes[0], nil
+	case 1:
+		// the constrained direction gets a larger eigenvalue than any other direction
+		u := directions[0]
+		penalty := scatter[0][0] + scatter[1][1] + scatter[2][2] + 1
+		projected := projectScatter(scatter, u)
+		v := [3]float64{u.X, u.Y, u.Z}
+		for a := 0; a < 3; a++ {
+			for b := 0; b < 3; b++ {
+				projected[a][b] += penalty * v[a] * v[b]
+			}
+		}
+		_, axes := eigenSymmetric3(projected)
+		return axes[0], nil
+	default:
+		return directions[0].Cross(directions[1]).Normalize(), nil
+	}
+}
+
+// projects a scatter matrix on the plane orthogonal to the unit direction u, P S P with P = I - u u^T
+func projectScatter(scatter [3][3]float64, u Point3D) [3][3]float64 {
+	v := [3]float64{u.X, u.Y, u.Z}
+	projection := [3][3]float64{}
+	for a := 0; a < 3; a++ {
+		for b := 0; b < 3; b++ {
+			projection[a][b] = -v[a] * v[b]
+		}
+		projection[a][a] += 1
+	}
+	result := [3][3]float64{}
+	for a := 0; a < 3; a++ {
+		for b := 0; b < 3; b++ {
+			for c := 0; c < 3; c++ {
+				for d := 0; d < 3; d++ {
+					result[a][b] += projection[a][c] * scatter[c][d] * projection[d][b]
+				}
+			}
+		}
+	}
+	return result
+}
+
+// ties the offsets of the planes at fixed distances apart
+// each set of planes connected by distances is shifted as a whole to the weighted least squares fit of its offsets,
+// the side of each plane being the one of its fitted offset; distances closing a cycle are ignored
+func getConstrainedOffsets(planes []Plane3DwSupport, offsets []float64, relations []PlaneRelation) []float64 {
+	// distances from each plane
+	edges := map[int][]PlaneRelation{}
+	for _, relation := range relations {
+		if relation.Kind == RELATION_DISTANCE {
+			edges[relation.First-1] = append(edges[relation.First-1], relation)
+			edges[relation.Second-1] = append(edges[relation.Second-1], relation)
+		}
+	}
+
+	result := append([]float64{}, offsets...)
+	visited := make([]bool, len(planes))
+	for root := range planes {
+		if visited[root] || len(edges[root]) == 0 {
+			continue
+		}
+		// offsets relative to the root, through a breadth first traversal
+		relative := map[int]float64{root: 0}
+		component := []int{root}
+		visited[root] = true
+		for k := 0; k < len(component); k++ {
+			i := component[k]
+			for _, edge := range edges[i] {
+				j := edge.Second - 1
+				if j == i {
+					j = edge.First - 1
+				}
+				if visited[j] {
+					continue
+				}
+				visited[j] = true
+				side := 1.0
+				if offsets[j] < offsets[i] {
+					side = -1
+				}
+				relative[j] = relative[i] + side*edge.Distance
+				component = append(component, j)
+			}
+		}
+		// weighted least squares shift of the component
+		shift, weight := 0.0, 0.0
+		for _, i := range component {
+			w := float64(planes[i].SupportSize)
+			shift += w * (offsets[i] - relative[i])
+			weight += w
+		}
+		if weight > 0 {
+			shift /= weight
+		}
+		for _, i := range component {
+			result[i] = shift + relative[i]
+		}
+	}
+	return result
+}
+
+// disjoint sets of indices
+type unionFind []int
+
+func newUnionFind(n int) unionFind {
+	parents := make(unionFind, n)
+	for i := range parents {
+		parents[i] = i
+	}
+	return parents
+}
+
+// representative of the set of i
+func (u unionFind) find(i int) int {
+	for u[i] != i {
+		u[i] = u[u[i]]
+		i = u[i]
+	}
+	return i
+}
+
+// merges the sets of i and j
+func (u unionFind) union(i, j int) {
+	u[u.find(i)] = u.find(j)
+}
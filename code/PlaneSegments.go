@@ -0,0 +1,99 @@
+package code
+
+import (
+	"math"
+	"sort"
+)
+
+// cell of a 2D occupancy grid
+type gridCell struct {
+	I int
+	J int
+}
+
+// splits the supporting points of a plane into spatially connected segments
+// points are projected into the plane frame and binned into an occupancy grid with cells of size cellSize,
+// occupied cells touching each other (including diagonally) belong to the same segment
+// segments with fewer than minSegmentSize points are not returned as segments, their points are returned as the remainder
+func SplitPlaneSegments(plane Plane3DwSupport, cellSize float64, minSegmentSize int) ([]Plane3DwSupport, []Point3D) {
+	// store the segments and the points of discarded segments
+	segments := []Plane3DwSupport{}
+	remainder := []Point3D{}
+
+	// nothing to split
+	if cellSize <= 0 || len(plane.SupportingPoints) == 0 {
+		return []Plane3DwSupport{plane}, remainder
+	}
+
+	// bin the points into the occupancy grid of the plane frame
+	frame := plane.GetFrame()
+	grid := map[gridCell][]Point3D{}
+	for _, point := range plane.SupportingPoints {
+		x, y := frame.ToPlane(point)
+		cell := gridCell{int(math.Floor(x / cellSize)), int(math.Floor(y / cellSize))}
+		grid[cell] = append(grid[cell], point)
+	}
+
+	// flood fill the occupied cells to find the connected segments
+	visited := map[gridCell]bool{}
+	for start := range grid {
+		if visited[start] {
+			continue
+		}
+		visited[start] = true
+		// points of the current segment
+		points := []Point3D{}
+		queue := []gridCell{start}
+		for len(queue) > 0 {
+			cell := queue[0]
+			queue = queue[1:]
+			points = append(points, grid[cell]...)
+			// visit the 8 neighbouring cells
+			for di := -1; di <= 1; di++ {
+				for dj := -1; dj <= 1; dj++ {
+					neighbour := gridCell{cell.I + di, cell.J + dj}
+					if _, occupied := grid[neighbour]; occupied && !visited[neighbour] {
+						visited[neighbour] = true
+						queue = append(queue, neighbour)
+					}
+				}
+			}
+		}
+
+		// keep the segment if it is large enough
+		if len(points) < minSegmentSize {
+			remainder = append(remainder, points...)
+			continue
+		}
+		segments = append(segments, Plane3DwSupport{
+			Plane3D:          plane.Plane3D,
+			SupportSize:      len(points),
+			SupportingPoints: points,
+		})
+	}
+
+	// largest segments first
+	sortBySupport(segments)
+
+	return segments, remainder
+}
+
+// splits each of the given planes into connected segments
+// returns all segments and the points of the discarded segments
+func splitDominantPlanes(planes []Plane3DwSupport, cellSize float64, minSegmentSize int) ([]Plane3DwSupport, []Point3D) {
+	segments := []Plane3DwSupport{}
+	remainder := []Point3D{}
+	for _, plane := range planes {
+		planeSegments, rest := SplitPlaneSegments(plane, cellSize, minSegmentSize)
+		segments = append(segments, planeSegments...)
+		remainder = append(remainder, rest...)
+	}
+	return segments, remainder
+}
+
+// sorts planes in decreasing order of support size
+func sortBySupport(planes []Plane3DwSupport) {
+	sort.SliceStable(planes, func(i, j int) bool {
+		return planes[i].SupportSize > planes[j].SupportSize
+	})
+}
@@ -2,6 +2,7 @@ package code
 
 import (
 	"fmt"
+	"math"
 )
 
 // Point3D represents a 3D point
@@ -19,4 +20,48 @@ type Point3D struct {
 // string representation of a Point3D
 func (p Point3D) String() string {
 	 return fmt.Sprintf("%f %f %f", p.X, p.Y, p.Z)
-}
\ No newline at end of file
+}
+
+// vector sum of the point and b
+func (p Point3D) Add(b Point3D) Point3D {
+	return Point3D{p.X + b.X, p.Y + b.Y, p.Z + b.Z}
+}
+
+// vector difference of the point and b
+func (p Point3D) Sub(b Point3D) Point3D {
+	return Point3D{p.X - b.X, p.Y - b.Y, p.Z - b.Z}
+}
+
+// vector scaled by s
+func (p Point3D) Scale(s float64) Point3D {
+	return Point3D{p.X * s, p.Y * s, p.Z * s}
+}
+
+// dot product of the vector and b
+func (p Point3D) Dot(b Point3D) float64 {
+	return p.X*b.X + p.Y*b.Y + p.Z*b.Z
+}
+
+// cross product of the vector and b
+func (p Point3D) Cross(b Point3D) Point3D {
+	return Point3D{p.Y*b.Z - p.Z*b.Y, p.Z*b.X - p.X*b.Z, p.X*b.Y - p.Y*b.X}
+}
+
+// length of the vector
+func (p Point3D) Norm() float64 {
+	return math.Sqrt(p.Dot(p))
+}
+
+// vector scaled to unit length (zero vector is returned unchanged)
+func (p Point3D) Normalize() Point3D {
+	n := p.Norm()
+	if n == 0 {
+		return p
+	}
+	return p.Scale(1 / n)
+}
+
+// distance between the point and b
+func (p Point3D) Distance(b Point3D) float64 {
+	return p.Sub(b).Norm()
+}
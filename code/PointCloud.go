@@ -1,6 +1,7 @@
 package code
 
 import (
+	"fmt"
 	"math/rand"
 )
 
@@ -8,6 +9,73 @@ import (
 type PointCloud struct {
 	// store the points
 	points []Point3D
+	// store per point attributes (such as intensity) by name, each aligned with the points
+	attributes map[string][]float64
+}
+
+// creates a point cloud from given points
+func NewPointCloud(points []Point3D) PointCloud {
+	return PointCloud{points: points}
+}
+
+// number of points of the point cloud
+func (pointCloud *PointCloud) Size() int {
+	return len(pointCloud.points)
+}
+
+// returns the values of a per point attribute
+func (pointCloud *PointCloud) GetAttribute(name string) ([]float64, bool) {
+	values, ok := pointCloud.attributes[name]
+	return values, ok
+}
+
+// sets the values of a per point attribute, one value per point
+func (pointCloud *PointCloud) SetAttribute(name string, values []float64) error {
+	if len(values) != len(pointCloud.points) {
+		return fmt.Errorf("attribute %s has %d values for %d points", name, len(values), len(pointCloud.points))
+	}
+	if pointCloud.attributes == nil {
+		pointCloud.attributes = map[string][]float64{}
+	}
+	pointCloud.attributes[name] = values
+	return nil
+}
+
+// creates a new point cloud with the points for which keep returns true, along with their attributes
+func (pointCloud *PointCloud) filter(keep func(index int) bool) PointCloud {
+	newCloud := PointCloud{points: []Point3D{}}
+	kept := []int{}
+	for i, point := range pointCloud.points {
+		if keep(i) {
+			newCloud.points = append(newCloud.points, point)
+			kept = append(kept, i)
+		}
+	}
+	for name, values := range pointCloud.attributes {
+		newValues := make([]float64, len(kept))
+		for j, i := range kept {
+			newValues[j] = values[i]
+		}
+		newCloud.SetAttribute(name, newValues)
+	}
+	return newCloud
+}
+
+// creates a new point cloud without the given points, along with the attributes of the points kept
+// each given point removes a single point of the point cloud equal to it
+func (pointCloud *PointCloud) withoutPoints(points []Point3D) PointCloud {
+	counts := map[Point3D]int{}
+	for _, point := range points {
+		counts[point]++
+	}
+	return pointCloud.filter(func(i int) bool {
+		point := pointCloud.points[i]
+		if counts[point] > 0 {
+			counts[point]--
+			return false
+		}
+		return true
+	})
 }
 
 // get a random point from PointCloud
@@ -30,7 +98,12 @@ func (pointCloud *PointCloud) RandomPointGenerator(done <-chan bool) <-chan Poin
 				if len(pointCloud.points) == 0 {
 					return
 				}
-				pointOut <- pointCloud.points[rand.Intn(len(pointCloud.points)+1) % (len(pointCloud.points)-1)]
+				// stop waiting for the receiver once done
+				select {
+				case pointOut <- pointCloud.points[rand.Intn(len(pointCloud.points))]:
+				case <-done:
+					return
+				}
 			}
 		}
 	}()
@@ -38,127 +111,103 @@ func (pointCloud *PointCloud) RandomPointGenerator(done <-chan bool) <-chan Poin
 	return pointOut
 }
 
-// get three random points from PointCloud
-func (pointCloud *PointCloud) GetRandomPoints(done <-chan bool) <-chan [3]Point3D {
-	dprint("********** GetRandomPoints started **********")
+// get samples of given size of random points from PointCloud
+func (pointCloud *PointCloud) GetRandomSamples(done <-chan bool, size int) <-chan []Point3D {
+	dprint("********** GetRandomSamples started **********")
 	// outbound channel
-	pointsOut := make(chan [3]Point3D)
+	samplesOut := make(chan []Point3D)
 	// channel to receive random points
 	chanRPG := pointCloud.RandomPointGenerator(done)
-	// goroutine to generate random points
+	// goroutine to generate samples
 	go func() {
-		defer close(pointsOut)
-		defer dprint("********** GetRandomPoints done **********")
+		defer close(samplesOut)
+		defer dprint("********** GetRandomSamples done **********")
 		for {
 			// until we receive a message on the done channel
-			// send an array containing points on the outbound channel
+			// send a slice containing points on the outbound channel
 			select {
 			case <-done:
 				return
 			default:
-				pointsOut <- [3]Point3D{<-chanRPG, <-chanRPG, <-chanRPG}
+				sample := make([]Point3D, size)
+				for i := range sample {
+					sample[i] = <-chanRPG
+				}
+				// stop waiting for the receiver once done
+				select {
+				case samplesOut <- sample:
+				case <-done:
+					return
+				}
 			}
 		}
 	}()
 	// return the outbound channel
-	return pointsOut
+	return samplesOut
+}
+
+// get three random points from PointCloud
+func (pointCloud *PointCloud) GetRandomPoints(done <-chan bool) <-chan [3]Point3D {
+	return convertC(pointCloud.GetRandomSamples(done, 3), toTriplet)
 }
 
 // receive arrays containing 3 Point3D through incoming channel and resend the array on the outbound channel until N arrays of Point3D
 func (pointCloud *PointCloud) TakeN(n int) <-chan [3]Point3D {
-	dprint("********** TakeN started **********")
+	return convertC(pointCloud.TakeNSamples(n, 3), toTriplet)
+}
+
+// converts a sample of 3 points to an array
+func toTriplet(sample []Point3D) [3]Point3D {
+	return [3]Point3D{sample[0], sample[1], sample[2]}
+}
+
+// receive samples of given size through incoming channel and resend them on the outbound channel until N samples
+// samples are drawn by the sampler if provided, and uniformly at random otherwise
+func (pointCloud *PointCloud) TakeNSamples(n int, size int, sampler ...Sampler) <-chan []Point3D {
+	dprint("********** TakeNSamples started **********")
 	// outbound channel (buffered since size already known)
-	arrOut := make(chan [3]Point3D, n)
+	// samples drawn by a sampler are sent as they are consumed, so that its state follows the run
+	samplesOut := make(chan []Point3D, n)
+	if len(sampler) > 0 {
+		samplesOut = make(chan []Point3D)
+	}
 	// done channel
 	done := make(chan bool)
 	// inbound channel
-	arrIn := pointCloud.GetRandomPoints(done)
-	// goroutine to send the array N times
+	var samplesIn <-chan []Point3D
+	if len(sampler) > 0 {
+		samplesIn = pointCloud.GetSamplerSamples(done, size, sampler[0])
+	} else {
+		samplesIn = pointCloud.GetRandomSamples(done, size)
+	}
+	// goroutine to send the samples N times
 	go func() {
-		defer close(arrOut)
+		defer close(samplesOut)
 		defer close(done)
 		// for n times
 		for i := 0; i < n; i++ {
-			// get array from inbound channel
-			arr := <-arrIn
-			// send array on outbound channel
-			arrOut <- arr
+			samplesOut <- <-samplesIn
 		}
-		// stop upstream channels
-		//done <- true
-		dprint("********** TakeN done **********")
+		dprint("********** TakeNSamples done **********")
 	}()
 	// return the outbound channel
-	return arrOut
+	return samplesOut
 }
 
 // method to return an array of points that support the plane
 func (pointCloud *PointCloud) GetSupportingPoints(plane Plane3D, eps float64) *[]Point3D {
-	// create an array of points that support the plane
-	supportingPoints := make([]Point3D, 0)
-
-	// iterate over all points
-	for _, point := range pointCloud.points {
-		// if the point is on the plane, add it to the array
-		if plane.GetDistance(&point) <= eps {
-			supportingPoints = append(supportingPoints, point)
-		}
-	}
-
-	// return the array of supporting points
+	supportingPoints := GetModelSupport[Plane3D](pointCloud, PlaneModel{}, plane, eps).SupportingPoints
 	return &supportingPoints
 }
 
 // method that receives Plane3D instance from inbound channel
 // returns Plane3DwSupport instance containing plane and the supporting points
 func (pointCloud *PointCloud) GetSupportingPointsC(planeIn <-chan Plane3D, eps float64) <-chan Plane3DwSupport {
-	dprint("********** GetSupportingPointsC started **********")
-	// outbound channel
-	planeOut := make(chan Plane3DwSupport)
-	// goroutine to get supporting points
-	go func() {
-		defer close(planeOut)
-		defer dprint("********** GetSupportingPointsC done **********")
-		// until we receive a plane from the inbound channel
-		for plane := range planeIn {
-			// create an array of points that support the plane
-			supportingPoints := make([]Point3D, 0)
-
-			// iterate over all points
-			for _, point := range pointCloud.points {
-				// if the point is on the plane, add it to the array
-				if plane.GetDistance(&point) <= eps {
-					supportingPoints = append(supportingPoints, point)
-				}
-			}
-			// send the plane with the supporting points on the outbound channel
-			planeOut <- Plane3DwSupport{
-				Plane3D: plane,
-				SupportingPoints: supportingPoints,
-				SupportSize: len(supportingPoints),
-			}
-		}
-	}()
-	// return the outbound channel
-	return planeOut
+	return convertC(GetModelSupportingPointsC[Plane3D](pointCloud, planeIn, PlaneModel{}, eps), newPlane3DwSupport)
 }
 
-
-
 // creates a new slice of points in which all points
 // belonging to the plane have been removed
 func (pointsCloud *PointCloud) RemovePlane(plane *Plane3D, eps float64) PointCloud {
-	// create a new slice of points
-	newPoints := []Point3D{}
-
-	// iterate through the points and add them to the new slice if they don't belong to the plane
-	for _, point := range pointsCloud.points {
-		// if the point is not on the plane, add it to the new slice
-		if plane.GetDistance(&point) > eps {
-			newPoints = append(newPoints, point)
-		}
-	}
-
-	// return the new slice
-	return PointCloud{ newPoints }
-}
\ No newline at end of file
+	return RemoveModel[Plane3D](pointsCloud, PlaneModel{}, *plane, eps)
+}
@@ -0,0 +1,389 @@
+package code
+
+import (
+	"math"
+	"sort"
+)
+
+// Point2D is a point in the 2D coordinates of a plane frame
+type Point2D struct {
+	X float64
+	Y float64
+}
+
+// cross product of the vectors o->a and o->b, positive if o, a, b turn counterclockwise
+func cross2D(o, a, b Point2D) float64 {
+	return (a.X-o.X)*(b.Y-o.Y) - (a.Y-o.Y)*(b.X-o.X)
+}
+
+// projects points onto the frame of a plane
+func (f *PlaneFrame) ToPlane2D(points []Point3D) []Point2D {
+	projected := make([]Point2D, len(points))
+	for i, point := range points {
+		x, y := f.ToPlane(point)
+		projected[i] = Point2D{x, y}
+	}
+	return projected
+}
+
+// returns the 3D points of the plane at the 2D coordinates of the frame
+func (f *PlaneFrame) FromPlane2D(points []Point2D) []Point3D {
+	result := make([]Point3D, len(points))
+	for i, point := range points {
+		result[i] = f.FromPlane(point.X, point.Y)
+	}
+	return result
+}
+
+// computes the convex hull of 2D points with Andrew's monotone chain
+// returns the vertices of the hull in counterclockwise order, without collinear points
+func ConvexHull2D(points []Point2D) []Point2D {
+	sorted := append([]Point2D{}, points...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].X != sorted[j].X {
+			return sorted[i].X < sorted[j].X
+		}
+		return sorted[i].Y < sorted[j].Y
+	})
+	if len(sorted) < 3 {
+		return sorted
+	}
+
+	// lower hull from left to right, then upper hull from right to left
+	hull := make([]Point2D, 0, 2*len(sorted))
+	for _, point := range sorted {
+		for len(hull) >= 2 && cross2D(hull[len(hull)-2], hull[len(hull)-1], point) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, point)
+	}
+	lower := len(hull) + 1
+	for i := len(sorted) - 2; i >= 0; i-- {
+		for len(hull) >= lower && cross2D(hull[len(hull)-2], hull[len(hull)-1], sorted[i]) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, sorted[i])
+	}
+	// the last point is the first one
+	return hull[:len(hull)-1]
+}
+
+// signed area of a polygon, positive if its vertices are in counterclockwise order
+func PolygonArea2D(polygon []Point2D) float64 {
+	area := 0.0
+	for i := range polygon {
+		j := (i + 1) % len(polygon)
+		area += polygon[i].X*polygon[j].Y - polygon[j].X*polygon[i].Y
+	}
+	return area / 2
+}
+
+// triangle of a Delaunay triangulation, with the circumcircle of its vertices
+type delaunayTriangle struct {
+	vertices [3]int
+	center   Point2D
+	radius2  float64
+	// triangles sharing the edges from each vertex to the next one (-1 if none)
+	neighbours [3]int
+	// the triangle has been replaced by the insertion of a point
+	removed bool
+}
+
+// creates a triangle of the points with given indices, whose vertices are ordered counterclockwise
+func newDelaunayTriangle(points []Point2D, a, b, c int) delaunayTriangle {
+	if cross2D(points[a], points[b], points[c]) < 0 {
+		b, c = c, b
+	}
+	pa, pb, pc := points[a], points[b], points[c]
+	triangle := delaunayTriangle{vertices: [3]int{a, b, c}, neighbours: [3]int{-1, -1, -1}}
+	d := 2 * (pa.X*(pb.Y-pc.Y) + pb.X*(pc.Y-pa.Y) + pc.X*(pa.Y-pb.Y))
+	if d == 0 {
+		triangle.radius2 = math.Inf(1)
+		return triangle
+	}
+	a2, b2, c2 := pa.X*pa.X+pa.Y*pa.Y, pb.X*pb.X+pb.Y*pb.Y, pc.X*pc.X+pc.Y*pc.Y
+	triangle.center = Point2D{
+		(a2*(pb.Y-pc.Y) + b2*(pc.Y-pa.Y) + c2*(pa.Y-pb.Y)) / d,
+		(a2*(pc.X-pb.X) + b2*(pa.X-pc.X) + c2*(pb.X-pa.X)) / d,
+	}
+	dx, dy := pa.X-triangle.center.X, pa.Y-triangle.center.Y
+	triangle.radius2 = dx*dx + dy*dy
+	return triangle
+}
+
+// reports whether a point lies strictly inside the circumcircle of the triangle
+func (t delaunayTriangle) inCircumcircle(point Point2D) bool {
+	dx, dy := point.X-t.center.X, point.Y-t.center.Y
+	return dx*dx+dy*dy < t.radius2
+}
+
+// returns the indices of the points in the order they are inserted in a triangulation, row by row of a grid of about
+// one point per cell, the rows being walked in alternate directions, so that consecutive points are close together
+func getInsertionOrder(points []Point2D, minX, minY, size float64) []int {
+	cells := math.Max(math.Floor(math.Sqrt(float64(len(points)))), 1)
+	cellSize := size / cells
+	row := func(i int) int { return int((points[i].Y - minY) / cellSize) }
+	order := make([]int, len(points))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		ri, rj := row(order[i]), row(order[j])
+		if ri != rj {
+			return ri < rj
+		}
+		if ri%2 == 1 {
+			return points[order[i]].X > points[order[j]].X
+		}
+		return points[order[i]].X < points[order[j]].X
+	})
+	return order
+}
+
+// computes the Delaunay triangulation of 2D points with the Bowyer-Watson algorithm
+// each point is located by walking across the triangles from the last one created, the points being inserted in
+// an order keeping consecutive points close together, and the triangles whose circumcircle contains it are found
+// among the neighbours of the triangle containing it, so that the triangulation takes about O(n log n) time
+// duplicate points are left out of the triangulation
+// returns the indices of the vertices of each triangle, in counterclockwise order
+func DelaunayTriangulation(points []Point2D) [][3]int {
+	if len(points) < 3 {
+		return [][3]int{}
+	}
+
+	// super triangle containing all the points, whose vertices are appended to the points
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, point := range points {
+		minX, minY = math.Min(minX, point.X), math.Min(minY, point.Y)
+		maxX, maxY = math.Max(maxX, point.X), math.Max(maxY, point.Y)
+	}
+	size := math.Max(math.Max(maxX-minX, maxY-minY), 1)
+	midX, midY := (minX+maxX)/2, (minY+maxY)/2
+	n := len(points)
+	vertices := append(append([]Point2D{}, points...),
+		Point2D{midX - 20*size, midY - size},
+		Point2D{midX, midY + 20*size},
+		Point2D{midX + 20*size, midY - size},
+	)
+	triangles := []delaunayTriangle{newDelaunayTriangle(vertices, n, n+1, n+2)}
+
+	// walks from the last triangle towards the point, crossing the edges the point lies beyond
+	// falls back to a search of all the triangles if the walk loops, as it may around degenerate triangles
+	last := 0
+	locate := func(point Point2D) int {
+		current := last
+		for steps := 0; steps < len(triangles); steps++ {
+			triangle := triangles[current]
+			next := -1
+			for k := 0; k < 3 && next < 0; k++ {
+				a, b := vertices[triangle.vertices[k]], vertices[triangle.vertices[(k+1)%3]]
+				if cross2D(a, b, point) < 0 && triangle.neighbours[k] >= 0 {
+					next = triangle.neighbours[k]
+				}
+			}
+			if next < 0 {
+				return current
+			}
+			current = next
+		}
+		for i, triangle := range triangles {
+			if !triangle.removed && triangle.inCircumcircle(point) {
+				return i
+			}
+		}
+		return -1
+	}
+
+	for _, i := range getInsertionOrder(points, minX, minY, size) {
+		point := vertices[i]
+		start := locate(point)
+		if start < 0 || !triangles[start].inCircumcircle(point) {
+			continue
+		}
+
+		// the cavity is made of the connected triangles whose circumcircle contains the point
+		cavity := []int{start}
+		inCavity := map[int]bool{start: true}
+		for k := 0; k < len(cavity); k++ {
+			for _, neighbour := range triangles[cavity[k]].neighbours {
+				if neighbour >= 0 && !inCavity[neighbour] && triangles[neighbour].inCircumcircle(point) {
+					inCavity[neighbour] = true
+					cavity = append(cavity, neighbour)
+				}
+			}
+		}
+
+		// connect the point to the boundary edges of the cavity, linking the new triangles to the triangles outside
+		// the cavity and to each other through the edges they share with the point
+		startingAt, endingAt := map[int]int{}, map[int]int{}
+		created := []int{}
+		for _, index := range cavity {
+			triangles[index].removed = true
+			for k := 0; k < 3; k++ {
+				outside := triangles[index].neighbours[k]
+				if outside >= 0 && inCavity[outside] {
+					continue
+				}
+				a, b := triangles[index].vertices[k], triangles[index].vertices[(k+1)%3]
+				triangle := newDelaunayTriangle(vertices, a, b, i)
+				// keep the edge a, b first so that its neighbour is the first one
+				triangle.vertices = [3]int{a, b, i}
+				triangle.neighbours[0] = outside
+				id := len(triangles)
+				if outside >= 0 {
+					for m := 0; m < 3; m++ {
+						if triangles[outside].neighbours[m] == index {
+							triangles[outside].neighbours[m] = id
+						}
+					}
+				}
+				startingAt[a], endingAt[b] = id, id
+				created = append(created, id)
+				triangles = append(triangles, triangle)
+			}
+		}
+		for _, id := range created {
+			a, b := triangles[id].vertices[0], triangles[id].vertices[1]
+			triangles[id].neighbours[1] = startingAt[b]
+			triangles[id].neighbours[2] = endingAt[a]
+		}
+		last = created[len(created)-1]
+	}
+
+	// drop the triangles touching the super triangle
+	result := [][3]int{}
+	for _, triangle := range triangles {
+		if !triangle.removed && triangle.vertices[0] < n && triangle.vertices[1] < n && triangle.vertices[2] < n {
+			result = append(result, triangle.vertices)
+		}
+	}
+	return result
+}
+
+// computes the alpha shape of 2D points, the union of the Delaunay triangles whose circumradius is at most alpha
+// returns the boundary loops of the shape, the largest first, the outer boundaries being counterclockwise and the
+// holes clockwise
+func AlphaShape2D(points []Point2D, alpha float64) [][]Point2D {
+	// count the directed edges of the kept triangles
+	edges := map[[2]int]bool{}
+	for _, triangle := range DelaunayTriangulation(points) {
+		t := newDelaunayTriangle(points, triangle[0], triangle[1], triangle[2])
+		if t.radius2 > alpha*alpha {
+			continue
+		}
+		for k := 0; k < 3; k++ {
+			edges[[2]int{triangle[k], triangle[(k+1)%3]}] = true
+		}
+	}
+
+	// boundary edges belong to a single triangle, so that their reverse is not an edge
+	next := map[int][]int{}
+	for edge := range edges {
+		if !edges[[2]int{edge[1], edge[0]}] {
+			next[edge[0]] = append(next[edge[0]], edge[1])
+		}
+	}
+	// visit the vertices in order, for the loops not to depend on the map order
+	starts := []int{}
+	for vertex := range next {
+		starts = append(starts, vertex)
+	}
+	sort.Ints(starts)
+
+	// chain the boundary edges into loops
+	loops := [][]Point2D{}
+	for _, start := range starts {
+		for len(next[start]) > 0 {
+			// follow the edges back to the start, each vertex having as many incoming as outgoing edges
+			loop := []Point2D{}
+			vertex := start
+			for len(next[vertex]) > 0 {
+				loop = append(loop, points[vertex])
+				following := next[vertex][len(next[vertex])-1]
+				next[vertex] = next[vertex][:len(next[vertex])-1]
+				vertex = following
+				if vertex == start {
+					break
+				}
+			}
+			if len(loop) >= 3 {
+				loops = append(loops, loop)
+			}
+		}
+	}
+
+	sort.SliceStable(loops, func(i, j int) bool {
+		return math.Abs(PolygonArea2D(loops[i])) > math.Abs(PolygonArea2D(loops[j]))
+	})
+	return loops
+}
+
+// reports whether a point lies inside or on the boundary of the counterclockwise triangle a, b, c
+func inTriangle2D(point, a, b, c Point2D) bool {
+	return cross2D(a, b, point) >= 0 && cross2D(b, c, point) >= 0 && cross2D(c, a, point) >= 0
+}
+
+// triangulates a simple polygon by ear clipping
+// returns the indices of the vertices of each triangle, counterclockwise whatever the order of the polygon
+// if no ear is left, as in polygons touching themselves, the remaining convex vertex with the widest triangle is
+// clipped, so that the whole polygon is always triangulated
+func TriangulatePolygon2D(polygon []Point2D) [][3]int {
+	triangles := [][3]int{}
+	if len(polygon) < 3 {
+		return triangles
+	}
+
+	// remaining vertices, counterclockwise
+	remaining := make([]int, len(polygon))
+	for i := range remaining {
+		remaining[i] = i
+	}
+	if PolygonArea2D(polygon) < 0 {
+		for i, j := 0, len(remaining)-1; i < j; i, j = i+1, j-1 {
+			remaining[i], remaining[j] = remaining[j], remaining[i]
+		}
+	}
+
+	// reports whether the vertex at position i of the remaining vertices is an ear
+	isEar := func(i int) bool {
+		n := len(remaining)
+		a, b, c := polygon[remaining[(i+n-1)%n]], polygon[remaining[i]], polygon[remaining[(i+1)%n]]
+		if cross2D(a, b, c) <= 0 {
+			return false
+		}
+		for _, vertex := range remaining {
+			point := polygon[vertex]
+			if point == a || point == b || point == c {
+				continue
+			}
+			if inTriangle2D(point, a, b, c) {
+				return false
+			}
+		}
+		return true
+	}
+
+	for len(remaining) > 3 {
+		n := len(remaining)
+		ear := -1
+		for i := 0; i < n && ear < 0; i++ {
+			if isEar(i) {
+				ear = i
+			}
+		}
+		if ear < 0 {
+			widest := math.Inf(-1)
+			for i := 0; i < n; i++ {
+				if area := cross2D(polygon[remaining[(i+n-1)%n]], polygon[remaining[i]], polygon[remaining[(i+1)%n]]); area > widest {
+					ear, widest = i, area
+				}
+			}
+		}
+		triangles = append(triangles, [3]int{remaining[(ear+n-1)%n], remaining[ear], remaining[(ear+1)%n]})
+		remaining = append(remaining[:ear], remaining[ear+1:]...)
+	}
+	if cross2D(polygon[remaining[0]], polygon[remaining[1]], polygon[remaining[2]]) != 0 {
+		triangles = append(triangles, [3]int{remaining[0], remaining[1], remaining[2]})
+	}
+	return triangles
+}
@@ -0,0 +1,155 @@
+package code
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// probability that a random point supports a wrong primitive, used by the non-randomness criterion of PROSAC
+const PROSAC_BETA float64 = 0.05
+
+// quantile of the normal distribution for a 5% probability that a wrong primitive passes the non-randomness criterion
+const PROSAC_NON_RANDOMNESS_QUANTILE float64 = 1.645
+
+// ProsacSampler draws samples progressively from the points with the best quality scores (PROSAC)
+// the first samples are drawn from the few top ranked points and the pool grows towards the whole point cloud
+// as the run goes on, so that the run falls back to uniform sampling if the scores are uninformative
+type ProsacSampler struct {
+	// guards the sampling state, read when updating the stopping criterion
+	mutex sync.Mutex
+	// indices of the points sorted by decreasing score
+	order []int
+	// size of the samples
+	m int
+	// maximum number of samples of the run
+	maxIterations int
+	// confidence used by the maximality criterion
+	confidence float64
+	// number of samples drawn
+	t int
+	// size of the pool of top ranked points
+	n int
+	// expected number of samples drawn from the top n points (T_n) and its integer counterpart (T'_n)
+	tn      float64
+	tnPrime int
+}
+
+// creates the PROSAC sampler of the points ranked by decreasing score
+// at most numOfIterations samples of sampleSize points are drawn, the run being stopped early once a primitive
+// satisfies the non-randomness and maximality criteria with given confidence
+func NewProsacSampler(scores []float64, sampleSize int, numOfIterations int, confidence float64) *ProsacSampler {
+	order := make([]int, len(scores))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return scores[order[i]] > scores[order[j]]
+	})
+
+	// expected number of samples drawn from the top m points among numOfIterations uniform samples
+	tn := float64(numOfIterations)
+	for i := 0; i < sampleSize; i++ {
+		tn *= float64(sampleSize-i) / float64(len(order)-i)
+	}
+
+	return &ProsacSampler{
+		order:         order,
+		m:             sampleSize,
+		maxIterations: numOfIterations,
+		confidence:    confidence,
+		n:             sampleSize,
+		tn:            tn,
+		tnPrime:       1,
+	}
+}
+
+// creates the factory of PROSAC samplers ranking the points by the values of given attribute of the point cloud
+// point clouds without the attribute are sampled uniformly, since they cannot be ranked
+func ProsacSamplerFactory(attribute string, confidence float64) SamplerFactory {
+	return func(pointCloud *PointCloud, sampleSize int, numOfIterations int) Sampler {
+		scores, ok := pointCloud.GetAttribute(attribute)
+		if !ok {
+			return NewUniformSampler(pointCloud.Size())
+		}
+		return NewProsacSampler(scores, sampleSize, numOfIterations, confidence)
+	}
+}
+
+// draws the next sample
+// it contains the n-th ranked point and m-1 points among the top n-1, or m points among the top n once
+// the pool has been sampled as much as a uniform sampler would have
+func (s *ProsacSampler) Sample(size int) []int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.t++
+	// grow the pool of top ranked points
+	if s.t > s.tnPrime && s.n < len(s.order) {
+		next := s.tn * float64(s.n+1) / float64(s.n+1-s.m)
+		s.tnPrime += int(math.Ceil(next - s.tn))
+		s.tn = next
+		s.n++
+	}
+
+	var positions []int
+	if s.tnPrime < s.t {
+		positions = distinctIndices(s.n, size, rand.Intn)
+	} else {
+		positions = append(distinctIndices(s.n-1, size-1, rand.Intn), s.n-1)
+	}
+
+	indices := make([]int, len(positions))
+	for i, position := range positions {
+		indices[i] = s.order[position]
+	}
+	return indices
+}
+
+// returns the number of samples after which the run can stop given the best primitive so far
+// the pool of top ranked points n* minimizing the number of samples needed to find an all-inlier sample with the
+// confidence of the run is chosen among the pools where the support is unlikely to be random
+// only pools containing all the samples drawn so far are considered, since the samples are counted from the start
+func (s *ProsacSampler) Update(supports func(index int) bool) int {
+	s.mutex.Lock()
+	pool := s.n
+	s.mutex.Unlock()
+
+	stop := s.maxIterations
+	inliers := 0
+	for n := 1; n <= len(s.order); n++ {
+		if supports(s.order[n-1]) {
+			inliers++
+		}
+		if n < pool || !s.isNonRandom(inliers, n) {
+			continue
+		}
+		if k := s.getMaximalityIterations(inliers, n); k < stop {
+			stop = k
+		}
+	}
+	return stop
+}
+
+// reports whether the number of inliers among the top n points is unlikely to be the support of a wrong primitive
+func (s *ProsacSampler) isNonRandom(inliers, n int) bool {
+	trials := float64(n - s.m)
+	minimum := float64(s.m) + PROSAC_BETA*trials + PROSAC_NON_RANDOMNESS_QUANTILE*math.Sqrt(PROSAC_BETA*(1-PROSAC_BETA)*trials)
+	return float64(inliers) >= minimum
+}
+
+// number of samples drawn from the top n points needed to find a sample of inliers with the confidence of the run
+func (s *ProsacSampler) getMaximalityIterations(inliers, n int) int {
+	// probability that a sample of the top n points only contains inliers
+	probability := 1.0
+	for j := 0; j < s.m; j++ {
+		probability *= float64(inliers-j) / float64(n-j)
+	}
+	if probability >= 1 {
+		return 1
+	}
+	if probability <= 0 {
+		return s.maxIterations
+	}
+	return int(math.Ceil(math.Log(1-s.confidence) / math.Log(1-probability)))
+}
@@ -2,6 +2,7 @@ package code
 
 import (
 	"bufio"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -71,16 +72,42 @@ func readXYZ(filename string, args ...string) (pointsCloud PointCloud, err error
 		scanner := bufio.NewScanner(file)
 		// store points in an array
 		points := []Point3D{}
+		// names of the per point attributes following the coordinates, and their values
+		attributeNames := []string{}
+		attributes := [][]float64{}
 
 		// read the first line of the file to get the points coordinates labels
+		// labels following the 3 coordinates labels name per point attributes
 		if scanner.Scan() {
 			pointsCoordinatesLabels = scanner.Text()
+			labels := strings.Fields(pointsCoordinatesLabels)
+			if len(labels) > 3 {
+				pointsCoordinatesLabels = strings.Join(labels[:3], " ")
+				attributeNames = labels[3:]
+				attributes = make([][]float64, len(attributeNames))
+			}
 		}
 
 		// read the file line by line and for each line read, extract the Point3D object and store it in the points array
 		for scanner.Scan() {
+			line := scanner.Text()
+			// split the attributes from the coordinates
+			if len(attributeNames) > 0 {
+				fields := strings.Fields(line)
+				if len(fields) != 3+len(attributeNames) {
+					return pointsCloud, errors.New("invalid number of values provided in line: " + line)
+				}
+				for i, field := range fields[3:] {
+					value, err := strconv.ParseFloat(field, 64)
+					if err != nil {
+						return pointsCloud, err
+					}
+					attributes[i] = append(attributes[i], value)
+				}
+				line = strings.Join(fields[:3], " ")
+			}
 			// attempt to extract a Point3D from the line information
-			point, err := getPoint3D(scanner.Text())
+			point, err := getPoint3D(line)
 			// if error, return the error and stop reading the file
 			if err != nil {
 				return pointsCloud, err
@@ -90,7 +117,45 @@ func readXYZ(filename string, args ...string) (pointsCloud PointCloud, err error
 		}
 
 		// return the pointsCloud
-		return PointCloud{ points }, nil
+		pointsCloud = NewPointCloud(points)
+		for i, name := range attributeNames {
+			pointsCloud.SetAttribute(name, attributes[i])
+		}
+		return pointsCloud, nil
+}
+
+// method to read a file containing one value per line, such as per point scores
+func readValues(filename string) ([]float64, error) {
+	// validate filename
+	if filename == "" {
+		return nil, errors.New("no filename provided")
+	}
+
+	// open the file
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.New("could not open file")
+	}
+
+	// if open successful, defer closing the file
+	defer file.Close()
+
+	// read the file line by line, skipping empty lines
+	values := []float64{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(line, 64)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+
+	return values, scanner.Err()
 }
 
 // save a file with provided filename and points data
@@ -139,4 +204,174 @@ func saveXYZ(filename string, points []Point3D, args ...string) error {
 	writer.Flush()
 
 	return nil
-}
\ No newline at end of file
+}
+
+// method to save the points of a point cloud to a file along with given attributes, as columns following the
+// coordinates named in the header
+func saveXYZAttributes(filename string, pointCloud *PointCloud, names ...string) error {
+	if filename == "" {
+		return errors.New("no filename provided")
+	}
+
+	values := make([][]float64, len(names))
+	for i, name := range names {
+		attribute, ok := pointCloud.GetAttribute(name)
+		if !ok {
+			return fmt.Errorf("unknown attribute: %s", name)
+		}
+		values[i] = attribute
+	}
+
+	fmt.Println("Saving file: " + filename)
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return errors.New("could not open file")
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	writer.WriteString(strings.Join(append([]string{pointsCoordinatesLabels}, names...), " ") + "\n")
+	for i, point := range pointCloud.points {
+		writer.WriteString(point.String())
+		for _, attribute := range values {
+			fmt.Fprintf(writer, " %f", attribute[i])
+		}
+		writer.WriteString("\n")
+	}
+	return writer.Flush()
+}
+
+// method to save line segments to a Wavefront OBJ file, as pairs of vertices joined by line elements
+func saveSegmentsOBJ(filename string, segments []Line3D) error {
+	if filename == "" {
+		return errors.New("no filename provided")
+	}
+
+	fmt.Println("Saving file: " + filename)
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return errors.New("could not open file")
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	for i, segment := range segments {
+		end := segment.Point.Add(segment.Direction.Scale(segment.Length))
+		_, err := fmt.Fprintf(writer, "v %f %f %f\nv %f %f %f\nl %d %d\n", segment.Point.X, segment.Point.Y, segment.Point.Z, end.X, end.Y, end.Z, 2*i+1, 2*i+2)
+		if err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+// rigid transform as saved to and read from a JSON file
+// the matrix takes precedence over the rotation, then the quaternion and the Euler angles, followed by the translation
+type transformFile struct {
+	Matrix      *Matrix4     `json:"matrix,omitempty"`
+	Rotation    *Matrix3     `json:"rotation,omitempty"`
+	Quaternion  *Quaternion  `json:"quaternion,omitempty"`
+	Euler       *EulerAngles `json:"euler,omitempty"`
+	Translation Point3D      `json:"translation"`
+}
+
+// method to read a rigid transform from a JSON file (.json extension), or from a text file holding the 3 or 4 rows of
+// its 4x4 matrix, with values separated by spaces or commas and lines starting with # ignored
+// returns an error if the file does not hold a rotation followed by a translation
+func ReadTransform(filename string) (RigidTransform, error) {
+	if filename == "" {
+		return RigidTransform{}, errors.New("no filename provided")
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return RigidTransform{}, errors.New("could not open file")
+	}
+
+	if strings.HasSuffix(strings.ToLower(filename), ".json") {
+		// either a bare 4x4 matrix or a transform object
+		var matrix Matrix4
+		if json.Unmarshal(data, &matrix) == nil {
+			return NewRigidTransform(matrix)
+		}
+		var file transformFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			return RigidTransform{}, err
+		}
+		switch {
+		case file.Matrix != nil:
+			return NewRigidTransform(*file.Matrix)
+		case file.Rotation != nil:
+			transform := RigidTransform{Rotation: *file.Rotation, Translation: file.Translation}
+			return NewRigidTransform(transform.Matrix())
+		case file.Quaternion != nil:
+			return RigidTransform{Rotation: file.Quaternion.Matrix(), Translation: file.Translation}, nil
+		case file.Euler != nil:
+			return RigidTransform{Rotation: file.Euler.Matrix(), Translation: file.Translation}, nil
+		default:
+			return RigidTransform{Translation: file.Translation, Rotation: Identity3()}, nil
+		}
+	}
+
+	matrix := Identity4()
+	rows := 0
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		values := strings.Fields(strings.ReplaceAll(line, ",", " "))
+		if rows == 4 || len(values) != 4 {
+			return RigidTransform{}, fmt.Errorf("invalid matrix row: %s", line)
+		}
+		for j, value := range values {
+			matrix[rows][j], err = strconv.ParseFloat(value, 64)
+			if err != nil {
+				return RigidTransform{}, err
+			}
+		}
+		rows++
+	}
+	if rows < 3 {
+		return RigidTransform{}, errors.New("matrix must have 3 or 4 rows")
+	}
+	return NewRigidTransform(matrix)
+}
+
+// method to save a rigid transform to a JSON file (.json extension), holding its 4x4 matrix, quaternion, Euler angles
+// and translation, or to a text file holding the 4 rows of its matrix
+func SaveTransform(filename string, transform RigidTransform) error {
+	if filename == "" {
+		return errors.New("no filename provided")
+	}
+
+	fmt.Println("Saving file: " + filename)
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return errors.New("could not open file")
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	matrix := transform.Matrix()
+	if strings.HasSuffix(strings.ToLower(filename), ".json") {
+		quaternion := transform.Rotation.Quaternion()
+		euler := transform.Rotation.EulerAngles()
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(transformFile{Matrix: &matrix, Quaternion: &quaternion, Euler: &euler, Translation: transform.Translation})
+		if err != nil {
+			return err
+		}
+		return writer.Flush()
+	}
+	for _, row := range matrix {
+		_, err := fmt.Fprintf(writer, "%.9f %.9f %.9f %.9f\n", row[0], row[1], row[2], row[3])
+		if err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
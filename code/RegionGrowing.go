@@ -0,0 +1,131 @@
+package code
+
+import (
+	"math"
+	"sort"
+)
+
+// default maximum angle in degrees between the normals of neighbouring points of the same region
+const DEFAULT_ANGLE_THRESHOLD float64 = 10
+
+// default maximum curvature of a point for it to seed further growth of a region
+const DEFAULT_CURVATURE_THRESHOLD float64 = 0.1
+
+// parameters of the region growing segmentation
+type RegionGrowingOptions struct {
+	// number of neighbours used for normal estimation and growth (0 uses DEFAULT_NORMAL_NEIGHBOURS)
+	Neighbours int
+	// maximum angle in degrees between normals of neighbouring points (0 uses DEFAULT_ANGLE_THRESHOLD)
+	AngleThreshold float64
+	// maximum curvature of a seed point (0 uses DEFAULT_CURVATURE_THRESHOLD)
+	CurvatureThreshold float64
+	// regions with fewer points are discarded
+	MinRegionSize int
+}
+
+// method to retrieve given number of dominant planes from the point cloud by region growing
+// regions are grown from the points of lowest curvature, adding neighbours whose normals are within the angle threshold
+// returns the largest regions as planes fitted by least squares, and the point cloud without the points belonging to them
+func getRegionGrowingPlanes(pointCloud PointCloud, options RegionGrowingOptions, numOfDominantPlanes ...int) ([]Plane3DwSupport, PointCloud) {
+	// if the number of dominant planes is not specified, set it to the default value
+	if len(numOfDominantPlanes) == 0 {
+		numOfDominantPlanes = []int{DEFAULT_NUM_OF_DOMINANT_PLANES}
+	}
+
+	// grow the regions and keep the largest ones
+	regions := pointCloud.GrowRegions(options)
+	if len(regions) > numOfDominantPlanes[0] {
+		regions = regions[:numOfDominantPlanes[0]]
+	}
+
+	// fit a plane to each region
+	dominantPlanes := []Plane3DwSupport{}
+	assigned := make([]bool, len(pointCloud.points))
+	for _, region := range regions {
+		points := make([]Point3D, len(region))
+		for i, index := range region {
+			points[i] = pointCloud.points[index]
+			assigned[index] = true
+		}
+		dominantPlanes = append(dominantPlanes, Plane3DwSupport{
+			Plane3D:          FitPlane(points),
+			SupportSize:      len(points),
+			SupportingPoints: points,
+		})
+	}
+
+	// remaining points
+	remaining := pointCloud.filter(func(i int) bool {
+		return !assigned[i]
+	})
+
+	return dominantPlanes, remaining
+}
+
+// segments the point cloud into smooth regions
+// returns the indices of the points of each region, in decreasing order of size
+func (pointCloud *PointCloud) GrowRegions(options RegionGrowingOptions) [][]int {
+	// use defaults for unset parameters
+	if options.Neighbours <= 0 {
+		options.Neighbours = DEFAULT_NORMAL_NEIGHBOURS
+	}
+	if options.AngleThreshold <= 0 {
+		options.AngleThreshold = DEFAULT_ANGLE_THRESHOLD
+	}
+	if options.CurvatureThreshold <= 0 {
+		options.CurvatureThreshold = DEFAULT_CURVATURE_THRESHOLD
+	}
+	minCosine := math.Cos(options.AngleThreshold * math.Pi / 180)
+
+	normals, curvatures := pointCloud.EstimateNormals(options.Neighbours)
+	tree := NewKDTree(pointCloud.points)
+
+	// visit the seed candidates in order of increasing curvature
+	order := make([]int, len(pointCloud.points))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return curvatures[order[i]] < curvatures[order[j]]
+	})
+
+	regions := [][]int{}
+	assigned := make([]bool, len(pointCloud.points))
+	for _, start := range order {
+		if assigned[start] {
+			continue
+		}
+		assigned[start] = true
+		region := []int{start}
+		seeds := []int{start}
+		for len(seeds) > 0 {
+			seed := seeds[0]
+			seeds = seeds[1:]
+			for _, neighbour := range tree.KNearest(pointCloud.points[seed], options.Neighbours) {
+				if assigned[neighbour] {
+					continue
+				}
+				// normals may point to either side of the surface
+				if math.Abs(normals[seed].Dot(normals[neighbour])) < minCosine {
+					continue
+				}
+				assigned[neighbour] = true
+				region = append(region, neighbour)
+				// only flat points continue the growth
+				if curvatures[neighbour] < options.CurvatureThreshold {
+					seeds = append(seeds, neighbour)
+				}
+			}
+		}
+		if len(region) >= options.MinRegionSize {
+			regions = append(regions, region)
+		}
+	}
+
+	// largest regions first
+	sort.SliceStable(regions, func(i, j int) bool {
+		return len(regions[i]) > len(regions[j])
+	})
+
+	return regions
+}
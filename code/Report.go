@@ -0,0 +1,168 @@
+package code
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// RunReport summarizes a RANSAC run and is saved as JSON next to the output files
+type RunReport struct {
+	Input                     string                `json:"input"`
+	Method                    string                `json:"method"`
+	Shape                     string                `json:"shape"`
+	Sampler                   string                `json:"sampler"`
+	ScoreAttribute            string                `json:"score_attribute,omitempty"`
+	Confidence                float64               `json:"confidence"`
+	PercentageOfPointsOnPlane float64               `json:"percentage_of_points_on_plane"`
+	Eps                       float64               `json:"eps"`
+	EstimatedNoise            float64               `json:"estimated_noise,omitempty"`
+	EpsSigmaFactor            float64               `json:"eps_sigma_factor,omitempty"`
+	Iterations                int                   `json:"iterations"`
+	TotalPoints               int                   `json:"total_points"`
+	Planes                    []PlaneReport         `json:"planes,omitempty"`
+	Shapes                    []ShapeReport         `json:"shapes,omitempty"`
+	RemainingPoints           int                   `json:"remaining_points"`
+	RemainderFile             string                `json:"remainder_file"`
+	Clusters                  []ClusterReport       `json:"clusters,omitempty"`
+	ManhattanFrame            *ManhattanFrameReport `json:"manhattan_frame,omitempty"`
+	Merges                    []PlaneMerge          `json:"merges,omitempty"`
+	Edges                     []PlaneEdge           `json:"edges,omitempty"`
+	Corners                   []PlaneCorner         `json:"corners,omitempty"`
+	EdgesFile                 string                `json:"edges_file,omitempty"`
+	MeshFile                  string                `json:"mesh_file,omitempty"`
+	Relations                 []RelationReport      `json:"relations,omitempty"`
+	EngineStats               *EngineStats          `json:"engine_stats,omitempty"`
+	Elevation                 *ElevationReport      `json:"elevation,omitempty"`
+}
+
+// PlaneReport describes a detected plane
+type PlaneReport struct {
+	File         string         `json:"file"`
+	Plane        Plane3D        `json:"plane"`
+	SupportSize  int            `json:"support_size"`
+	Metrics      *PlaneMetrics  `json:"metrics,omitempty"`
+	Boundary     *PlaneBoundary `json:"boundary,omitempty"`
+	BoundaryFile string         `json:"boundary_file,omitempty"`
+	MeshFile     string         `json:"mesh_file,omitempty"`
+}
+
+// ManhattanFrameReport describes the Manhattan frame of the planes
+// the rows of the rotation, which maps the coordinates of the point cloud to the frame, are the axes
+type ManhattanFrameReport struct {
+	Axes     [3]Point3D `json:"axes"`
+	Rotation Matrix3    `json:"rotation"`
+}
+
+// RelationReport describes a relationship between 2 planes, and their angle (degrees) and offset (distance, for
+// parallel planes) before and after the joint refinement
+type RelationReport struct {
+	PlaneRelation
+	AngleBefore  float64 `json:"angle_before"`
+	AngleAfter   float64 `json:"angle_after"`
+	OffsetBefore float64 `json:"offset_before,omitempty"`
+	OffsetAfter  float64 `json:"offset_after,omitempty"`
+}
+
+// ElevationReport describes the heights of the points above a dominant plane, whose unit normal points up, and
+// their elevation grid, whose lower left corner is the origin and whose columns and rows run along U and V
+type ElevationReport struct {
+	Plane       int                    `json:"plane"`
+	Normal      Point3D                `json:"normal"`
+	HeightsFile string                 `json:"heights_file"`
+	MinHeight   float64                `json:"min_height"`
+	MaxHeight   float64                `json:"max_height"`
+	CellSize    float64                `json:"cell_size,omitempty"`
+	Columns     int                    `json:"columns,omitempty"`
+	Rows        int                    `json:"rows,omitempty"`
+	Origin      *Point3D               `json:"origin,omitempty"`
+	U           *Point3D               `json:"u,omitempty"`
+	V           *Point3D               `json:"v,omitempty"`
+	Layers      []ElevationLayerReport `json:"layers,omitempty"`
+}
+
+// ElevationLayerReport describes the files of a statistic of the heights of the cells of an elevation grid, and the
+// heights of the lowest and highest gray levels of its image
+type ElevationLayerReport struct {
+	Stat      string  `json:"stat"`
+	GridFile  string  `json:"grid_file"`
+	ImageFile string  `json:"image_file"`
+	ImageMin  float64 `json:"image_min"`
+	ImageMax  float64 `json:"image_max"`
+}
+
+// ShapeReport describes a detected shape other than a plane
+type ShapeReport struct {
+	File        string `json:"file"`
+	Model       any    `json:"model"`
+	SupportSize int    `json:"support_size"`
+}
+
+// ClusterReport describes a cluster of the points not covered by the dominant planes
+type ClusterReport struct {
+	File     string      `json:"file"`
+	Size     int         `json:"size"`
+	Centroid Point3D     `json:"centroid"`
+	Min      Point3D     `json:"min"`
+	Max      Point3D     `json:"max"`
+	Box      OrientedBox `json:"oriented_box"`
+}
+
+// creates the report entry of a plane saved to given file
+func newPlaneReport(plane Plane3DwSupport, file string) PlaneReport {
+	return PlaneReport{File: file, Plane: plane.Plane3D, SupportSize: plane.SupportSize, Metrics: plane.Metrics}
+}
+
+// creates the report entry of a shape saved to given file
+func newShapeReport(shape DetectedModel, file string) ShapeReport {
+	return ShapeReport{File: file, Model: shape.Model, SupportSize: shape.SupportSize}
+}
+
+// creates the report entry of a cluster saved to given file
+func newClusterReport(cluster Cluster, file string) ClusterReport {
+	return ClusterReport{
+		File:     file,
+		Size:     cluster.Size,
+		Centroid: cluster.Centroid,
+		Min:      cluster.Min,
+		Max:      cluster.Max,
+		Box:      cluster.Box,
+	}
+}
+
+// creates the report of a Manhattan frame
+func newManhattanFrameReport(frame ManhattanFrame) *ManhattanFrameReport {
+	return &ManhattanFrameReport{Axes: frame.Axes, Rotation: frame.Rotation()}
+}
+
+// creates the report of a relationship between 2 planes given the planes before and after the refinement
+func newRelationReport(relation PlaneRelation, first, second, refinedFirst, refinedSecond Plane3D) RelationReport {
+	report := RelationReport{
+		PlaneRelation: relation,
+		AngleBefore:   getPlaneAngle(first, second),
+		AngleAfter:    getPlaneAngle(refinedFirst, refinedSecond),
+	}
+	if relation.Kind != RELATION_ORTHOGONAL {
+		report.OffsetBefore = getPlaneOffset(first, second)
+		report.OffsetAfter = getPlaneOffset(refinedFirst, refinedSecond)
+	}
+	return report
+}
+
+// save the report as indented JSON to a file with provided filename
+func saveReport(filename string, report any) error {
+	// validate filename
+	if filename == "" {
+		return errors.New("no filename provided")
+	}
+
+	fmt.Println("Saving file: " + filename)
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filename, append(data, '\n'), 0644)
+}
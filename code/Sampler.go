@@ -0,0 +1,88 @@
+package code
+
+import (
+	"math/rand"
+)
+
+// Sampler draws the minimal samples of a RANSAC run, as indices of points of the point cloud
+type Sampler interface {
+	// returns the indices of the points of the next sample of given size
+	Sample(size int) []int
+}
+
+// creates the sampler drawing samples of given size from a point cloud, for a run of at most numOfIterations samples
+type SamplerFactory func(pointCloud *PointCloud, sampleSize int, numOfIterations int) Sampler
+
+// UniformSampler draws samples of distinct points uniformly at random
+type UniformSampler struct {
+	// number of points of the point cloud
+	n int
+}
+
+// creates the sampler drawing samples uniformly among n points
+func NewUniformSampler(n int) *UniformSampler {
+	return &UniformSampler{n: n}
+}
+
+// draws the next sample
+func (s *UniformSampler) Sample(size int) []int {
+	return distinctIndices(s.n, size, rand.Intn)
+}
+
+// samplers which can end a run before the maximum number of samples have been evaluated
+type stoppingSampler interface {
+	// updates the stopping criterion with the best hypothesis found so far, given by a predicate on the point indices
+	// telling whether a point supports it, and returns the number of samples after which the run can stop
+	Update(supports func(index int) bool) int
+}
+
+// get samples of given size drawn by the sampler from PointCloud
+func (pointCloud *PointCloud) GetSamplerSamples(done <-chan bool, size int, sampler Sampler) <-chan []Point3D {
+	dprint("********** GetSamplerSamples started **********")
+	// outbound channel
+	samplesOut := make(chan []Point3D)
+	// goroutine to generate samples
+	go func() {
+		defer close(samplesOut)
+		defer dprint("********** GetSamplerSamples done **********")
+		for {
+			// until we receive a message on the done channel
+			// send a slice containing points on the outbound channel
+			sample := []Point3D{}
+			for _, index := range sampler.Sample(size) {
+				sample = append(sample, pointCloud.points[index])
+			}
+			select {
+			case samplesOut <- sample:
+			case <-done:
+				return
+			}
+		}
+	}()
+	// return the outbound channel
+	return samplesOut
+}
+
+// draws count distinct indices uniformly from [0, n) using the random generator function intn
+// the indices in exclude are never drawn, and all the other indices are returned if there are not count of them
+func distinctIndices(n, count int, intn func(int) int, exclude ...int) []int {
+	if count > n-len(exclude) {
+		count = n - len(exclude)
+	}
+	indices := make([]int, 0, count+len(exclude))
+	indices = append(indices, exclude...)
+	for len(indices) < count+len(exclude) {
+		index := intn(n)
+		unique := true
+		for _, other := range indices {
+			if other == index {
+				unique = false
+				break
+			}
+		}
+		if unique {
+			indices = append(indices, index)
+		}
+	}
+	return indices[len(exclude):]
+}
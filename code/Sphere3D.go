@@ -0,0 +1,119 @@
+package code
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// number of points needed to define a sphere
+const SPHERE_SAMPLE_SIZE int = 4
+
+// Sphere3D represents a sphere
+type Sphere3D struct {
+	Center Point3D
+	Radius float64
+}
+
+// computes the sphere passing through 4 points
+// returns an error if the points are coplanar
+func GetSphere(p1, p2, p3, p4 Point3D) (Sphere3D, error) {
+	// the center c satisfies 2 (pi - p1) . c = |pi|^2 - |p1|^2 for i = 2, 3, 4
+	a := [3]Point3D{p2.Sub(p1).Scale(2), p3.Sub(p1).Scale(2), p4.Sub(p1).Scale(2)}
+	b := [3]float64{p2.Dot(p2) - p1.Dot(p1), p3.Dot(p3) - p1.Dot(p1), p4.Dot(p4) - p1.Dot(p1)}
+
+	// solve the linear system using Cramer's rule
+	det := a[0].Dot(a[1].Cross(a[2]))
+	if isCoplanar(a, det) {
+		return Sphere3D{}, errors.New("points are coplanar")
+	}
+	// columns of the matrix of the system
+	cx := Point3D{a[0].X, a[1].X, a[2].X}
+	cy := Point3D{a[0].Y, a[1].Y, a[2].Y}
+	cz := Point3D{a[0].Z, a[1].Z, a[2].Z}
+	rhs := Point3D{b[0], b[1], b[2]}
+	center := Point3D{
+		rhs.Dot(cy.Cross(cz)) / det,
+		cx.Dot(rhs.Cross(cz)) / det,
+		cx.Dot(cy.Cross(rhs)) / det,
+	}
+
+	return Sphere3D{center, p1.Sub(center).Norm()}, nil
+}
+
+// reports whether the edge vectors of a tetrahedron, with given triple product, span no volume
+func isCoplanar(edges [3]Point3D, det float64) bool {
+	scale := edges[0].Norm() * edges[1].Norm() * edges[2].Norm()
+	return scale == 0 || math.Abs(det) < 1e-9*scale
+}
+
+// calculate distance of a point to the surface of the sphere
+func (s *Sphere3D) GetDistance(point *Point3D) float64 {
+	return math.Abs((*point).Sub(s.Center).Norm() - s.Radius)
+}
+
+// string representation of a Sphere3D
+func (s Sphere3D) String() string {
+	return fmt.Sprintf("center=(%v), radius=%f", s.Center, s.Radius)
+}
+
+// SphereModel is the Model of spheres, fitted to samples of 4 points
+// spheres with radius out of [MinRadius, MaxRadius] are rejected (MaxRadius 0 means no upper limit)
+type SphereModel struct {
+	MinRadius float64
+	MaxRadius float64
+}
+
+func (m SphereModel) SampleSize() int {
+	return SPHERE_SAMPLE_SIZE
+}
+
+func (m SphereModel) IsDegenerate(sample []Point3D) bool {
+	edges := [3]Point3D{sample[1].Sub(sample[0]), sample[2].Sub(sample[0]), sample[3].Sub(sample[0])}
+	return isCoplanar(edges, edges[0].Dot(edges[1].Cross(edges[2])))
+}
+
+func (m SphereModel) Fit(sample []Point3D) (Sphere3D, error) {
+	sphere, err := GetSphere(sample[0], sample[1], sample[2], sample[3])
+	if err != nil {
+		return sphere, err
+	}
+	return sphere, m.validate(sphere)
+}
+
+func (m SphereModel) Residual(sphere Sphere3D, point *Point3D) float64 {
+	return sphere.GetDistance(point)
+}
+
+// least squares sphere of the points, minimizing the algebraic distance |p - c|^2 - r^2
+func (m SphereModel) Refit(sphere Sphere3D, points []Point3D) (Sphere3D, error) {
+	// center the points for numerical stability
+	centroid := GetCentroid(points)
+	rows := make([][]float64, len(points))
+	rhs := make([]float64, len(points))
+	for i, point := range points {
+		p := point.Sub(centroid)
+		// |p|^2 = 2 c . p + (r^2 - |c|^2)
+		rows[i] = []float64{2 * p.X, 2 * p.Y, 2 * p.Z, 1}
+		rhs[i] = p.Dot(p)
+	}
+	x, err := solveLeastSquares(rows, rhs)
+	if err != nil {
+		return sphere, err
+	}
+	center := Point3D{x[0], x[1], x[2]}
+	radius2 := x[3] + center.Dot(center)
+	if radius2 <= 0 {
+		return sphere, errors.New("invalid sphere")
+	}
+	refitted := Sphere3D{center.Add(centroid), math.Sqrt(radius2)}
+	return refitted, m.validate(refitted)
+}
+
+// checks the radius constraints
+func (m SphereModel) validate(sphere Sphere3D) error {
+	if sphere.Radius < m.MinRadius || (m.MaxRadius > 0 && sphere.Radius > m.MaxRadius) {
+		return errors.New("sphere radius out of range")
+	}
+	return nil
+}
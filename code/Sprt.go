@@ -0,0 +1,166 @@
+package code
+
+import (
+	"math"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// probability that a point supports a bad hypothesis, assumed until it is estimated from the rejected hypotheses
+const SPRT_INITIAL_DELTA float64 = 0.05
+
+// weight, in points, of SPRT_INITIAL_DELTA in the estimate of the probability from the rejected hypotheses
+const SPRT_DELTA_PRIOR_WEIGHT float64 = 100
+
+// time needed to fit a hypothesis, in units of the time needed to check whether a point supports it
+const SPRT_FIT_COST float64 = 200
+
+// sprtVerifier scores hypotheses with Wald's Sequential Probability Ratio Test
+// the points are checked in random order, and a hypothesis is rejected as soon as the likelihood ratio of it being bad
+// rather than as good as the best hypothesis so far exceeds the decision threshold
+// the verifier is shared by the verification workers, the best so far being updated by the fan in
+type sprtVerifier[T any] struct {
+	pointCloud *PointCloud
+	model      Model[T]
+	eps        float64
+	// random order in which the points are checked
+	order []int
+
+	mutex sync.RWMutex
+	// fraction of the points supporting the best hypothesis so far (epsilon)
+	inlierRatio float64
+	// probability that a point supports a bad hypothesis (delta)
+	delta float64
+	// decision threshold of the likelihood ratio (A)
+	threshold float64
+	// points checked by the rejected hypotheses and how many supported them, used to estimate delta
+	rejectedChecks  int
+	rejectedInliers int
+	// number of hypotheses verified and rejected, and of points checked
+	verified int
+	rejected int
+	checks   int
+}
+
+// creates the verifier of the hypotheses of the model for the point cloud
+func newSprtVerifier[T any](pointCloud *PointCloud, model Model[T], eps float64) *sprtVerifier[T] {
+	return &sprtVerifier[T]{
+		pointCloud: pointCloud,
+		model:      model,
+		eps:        eps,
+		order:      rand.Perm(len(pointCloud.points)),
+		delta:      SPRT_INITIAL_DELTA,
+	}
+}
+
+// updates the test with the support size of the best hypothesis so far
+func (v *sprtVerifier[T]) update(supportSize int) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.inlierRatio = float64(supportSize) / float64(len(v.pointCloud.points))
+	v.threshold = getSprtThreshold(v.inlierRatio, v.delta)
+}
+
+// computes the decision threshold A of the test for given inlier ratio epsilon and probability delta
+// A is the fixed point of A = K / C + 1 + ln A, where K is the cost of fitting a hypothesis and C the information
+// gained by checking a point
+// returns 0 (no rejection) if a point is not more likely to support a good hypothesis than a bad one
+func getSprtThreshold(epsilon, delta float64) float64 {
+	if epsilon <= delta || epsilon >= 1 {
+		return 0
+	}
+	c := (1-delta)*math.Log((1-delta)/(1-epsilon)) + delta*math.Log(delta/epsilon)
+	threshold := SPRT_FIT_COST/c + 1
+	for i := 0; i < 10; i++ {
+		threshold = SPRT_FIT_COST/c + 1 + math.Log(threshold)
+	}
+	return threshold
+}
+
+// scores the hypothesis, returning false if it is rejected before all points are checked
+// the supporting points of a hypothesis passing the test are those found while testing it, in point cloud order
+func (v *sprtVerifier[T]) verify(primitive T) (ModelWithSupport[T], bool) {
+	v.mutex.RLock()
+	epsilon, delta, threshold := v.inlierRatio, v.delta, v.threshold
+	v.mutex.RUnlock()
+
+	// likelihood ratio of the hypothesis being bad rather than good
+	ratio := 1.0
+	inliers := []int{}
+	for i, index := range v.order {
+		if v.model.Residual(primitive, &v.pointCloud.points[index]) <= v.eps {
+			ratio *= delta / epsilon
+			inliers = append(inliers, index)
+		} else {
+			ratio *= (1 - delta) / (1 - epsilon)
+		}
+		if threshold > 0 && ratio > threshold {
+			v.reject(i+1, len(inliers))
+			return ModelWithSupport[T]{}, false
+		}
+	}
+
+	v.mutex.Lock()
+	v.verified++
+	v.checks += len(v.order)
+	v.mutex.Unlock()
+
+	sort.Ints(inliers)
+	supportingPoints := make([]Point3D, len(inliers))
+	for i, index := range inliers {
+		supportingPoints[i] = v.pointCloud.points[index]
+	}
+	return ModelWithSupport[T]{Model: primitive, SupportSize: len(supportingPoints), SupportingPoints: supportingPoints}, true
+}
+
+// records a rejected hypothesis and re-estimates delta from the points it checked
+func (v *sprtVerifier[T]) reject(checks, inliers int) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.verified++
+	v.rejected++
+	v.checks += checks
+	v.rejectedChecks += checks
+	v.rejectedInliers += inliers
+	v.delta = (float64(v.rejectedInliers) + SPRT_INITIAL_DELTA*SPRT_DELTA_PRIOR_WEIGHT) / (float64(v.rejectedChecks) + SPRT_DELTA_PRIOR_WEIGHT)
+	v.threshold = getSprtThreshold(v.inlierRatio, v.delta)
+}
+
+// adds the counters of the verifier to the engine stats
+func (v *sprtVerifier[T]) addStats(stats *EngineStats) {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	stats.VerifiedHypotheses += v.verified
+	stats.RejectedHypotheses += v.rejected
+	stats.CheckedPoints += v.checks
+}
+
+// receives primitives from inbound channel and sends back those passing the test with their supporting points
+// the primitives are verified by goroutines, one per CPU
+func getVerifiedModelsC[T any](primitiveIn <-chan T, verifier *sprtVerifier[T]) <-chan ModelWithSupport[T] {
+	dprint("********** getVerifiedModelsC started **********")
+	// outbound channel
+	primitiveOut := make(chan ModelWithSupport[T])
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for primitive := range primitiveIn {
+				if support, ok := verifier.verify(primitive); ok {
+					primitiveOut <- support
+				}
+			}
+		}()
+	}
+	// close the outbound channel once all workers are done
+	go func() {
+		wg.Wait()
+		close(primitiveOut)
+		dprint("********** getVerifiedModelsC done **********")
+	}()
+	// return the outbound channel
+	return primitiveOut
+}
@@ -5,8 +5,14 @@ import (
 	"os"
 )
 
-func TestRANSAC(filename string, confidence, percentageOfPointsOnPlane, eps float64) {
+// runs the plane segmentation on given file without saving output files
+// returns the number of points covered by dominant planes
+func TestRANSAC(filename string, confidence, percentageOfPointsOnPlane, eps float64, options ...RansacOptions) int {
 	fmt.Println("Test RANSAC run")
+	// use default options if none provided
+	if len(options) == 0 {
+		options = []RansacOptions{{}}
+	}
 	// get the PointCloud
 	pointCloud, err := readXYZ(filename)
 	// if error extracting point cloud, print error and exit
@@ -15,11 +21,38 @@ func TestRANSAC(filename string, confidence, percentageOfPointsOnPlane, eps floa
 		os.Exit(1)
 	}
 
+	// estimate eps from the noise of the point cloud if requested
+	eps, _, err = getEps(&pointCloud, eps, options[0])
+	if err != nil {
+		fmt.Println("Unable to estimate epsilon", err)
+		os.Exit(1)
+	}
+
+	// attach the quality scores ranking the points
+	if options[0].Sampler == SAMPLER_PROSAC {
+		err = setScores(&pointCloud, options[0])
+		if err != nil {
+			fmt.Println("Unable to get quality scores", err)
+			os.Exit(1)
+		}
+	}
+
 	// calculate number of iterations
 	numOfIterations := getNumberOfIterations(confidence, percentageOfPointsOnPlane)
 
+	// estimate the Manhattan frame of the planes
+	err = setManhattanFrame(&pointCloud, &options[0])
+	if err != nil {
+		fmt.Println("Unable to estimate Manhattan frame", err)
+		os.Exit(1)
+	}
+
 	// get the dominant planes and the point cloud without the points belonging to the dominant planes
-	dominantPlanes, cloud := getDominantPlanes(numOfIterations, pointCloud, eps)
+	dominantPlanes, cloud, err := detectDominantPlanes(numOfIterations, pointCloud, eps, options[0], getEngineOptions(options[0], confidence, eps))
+	if err != nil {
+		fmt.Println("Unable to detect dominant planes", err)
+		os.Exit(1)
+	}
 
 	// size of points covered by dominant planes
 	dominantPlanesSize := 0
@@ -37,4 +70,6 @@ func TestRANSAC(filename string, confidence, percentageOfPointsOnPlane, eps floa
 	} else {
 		fmt.Println("Test RANSAC run completed")
 	}
+
+	return dominantPlanesSize
 }
\ No newline at end of file
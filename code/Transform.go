@@ -0,0 +1,333 @@
+package code
+
+import (
+	"errors"
+	"math"
+	"runtime"
+	"sync"
+)
+
+// tolerance of the checks that a matrix is a rotation
+const ROTATION_TOLERANCE float64 = 1e-6
+
+// Matrix3 is a 3x3 matrix, indexed by row then column
+type Matrix3 [3][3]float64
+
+// Matrix4 is a 4x4 matrix acting on homogeneous coordinates, indexed by row then column
+type Matrix4 [4][4]float64
+
+// identity 3x3 matrix
+func Identity3() Matrix3 {
+	return Matrix3{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+}
+
+// identity 4x4 matrix
+func Identity4() Matrix4 {
+	return Matrix4{{1, 0, 0, 0}, {0, 1, 0, 0}, {0, 0, 1, 0}, {0, 0, 0, 1}}
+}
+
+// matrix product m n
+func (m Matrix3) Mul(n Matrix3) Matrix3 {
+	result := Matrix3{}
+	for a := 0; a < 3; a++ {
+		for b := 0; b < 3; b++ {
+			for c := 0; c < 3; c++ {
+				result[a][b] += m[a][c] * n[c][b]
+			}
+		}
+	}
+	return result
+}
+
+// product of the matrix and the column vector v
+func (m Matrix3) MulVector(v Point3D) Point3D {
+	return Point3D{
+		m[0][0]*v.X + m[0][1]*v.Y + m[0][2]*v.Z,
+		m[1][0]*v.X + m[1][1]*v.Y + m[1][2]*v.Z,
+		m[2][0]*v.X + m[2][1]*v.Y + m[2][2]*v.Z,
+	}
+}
+
+// transpose of the matrix, which is its inverse if it is a rotation
+func (m Matrix3) Transpose() Matrix3 {
+	result := Matrix3{}
+	for a := 0; a < 3; a++ {
+		for b := 0; b < 3; b++ {
+			result[a][b] = m[b][a]
+		}
+	}
+	return result
+}
+
+// determinant of the matrix
+func (m Matrix3) Det() float64 {
+	return Point3D{m[0][0], m[0][1], m[0][2]}.Dot(Point3D{m[1][0], m[1][1], m[1][2]}.Cross(Point3D{m[2][0], m[2][1], m[2][2]}))
+}
+
+// reports whether the matrix is a rotation, orthonormal with determinant 1, within ROTATION_TOLERANCE
+func (m Matrix3) IsRotation() bool {
+	product := m.Mul(m.Transpose())
+	identity := Identity3()
+	for a := 0; a < 3; a++ {
+		for b := 0; b < 3; b++ {
+			if math.Abs(product[a][b]-identity[a][b]) > ROTATION_TOLERANCE {
+				return false
+			}
+		}
+	}
+	return math.Abs(m.Det()-1) <= ROTATION_TOLERANCE
+}
+
+// matrix product m n, the transform applying n then m
+func (m Matrix4) Mul(n Matrix4) Matrix4 {
+	result := Matrix4{}
+	for a := 0; a < 4; a++ {
+		for b := 0; b < 4; b++ {
+			for c := 0; c < 4; c++ {
+				result[a][b] += m[a][c] * n[c][b]
+			}
+		}
+	}
+	return result
+}
+
+// rotation matrix of the rotation by angle radians about a unit axis (Rodrigues' formula)
+func AxisAngleRotation(axis Point3D, angle float64) Matrix3 {
+	k := axis.Normalize()
+	kx := Matrix3{{0, -k.Z, k.Y}, {k.Z, 0, -k.X}, {-k.Y, k.X, 0}}
+	kk := kx.Mul(kx)
+	rotation := Identity3()
+	sine, cosine := math.Sin(angle), math.Cos(angle)
+	for a := 0; a < 3; a++ {
+		for b := 0; b < 3; b++ {
+			rotation[a][b] += sine*kx[a][b] + (1-cosine)*kk[a][b]
+		}
+	}
+	return rotation
+}
+
+// smallest rotation mapping the direction from onto the direction to
+// opposite directions are mapped by a half turn about an axis perpendicular to them
+func RotationBetween(from, to Point3D) Matrix3 {
+	u, v := from.Normalize(), to.Normalize()
+	axis := u.Cross(v)
+	sine, cosine := axis.Norm(), u.Dot(v)
+	if sine > 1e-12 {
+		return AxisAngleRotation(axis, math.Atan2(sine, cosine))
+	}
+	if cosine > 0 {
+		return Identity3()
+	}
+	// any axis perpendicular to the directions
+	perpendicular := u.Cross(Point3D{1, 0, 0})
+	if perpendicular.Norm() < 1e-6 {
+		perpendicular = u.Cross(Point3D{0, 1, 0})
+	}
+	return AxisAngleRotation(perpendicular, math.Pi)
+}
+
+// Quaternion is a rotation quaternion W + X i + Y j + Z k
+type Quaternion struct {
+	W float64 `json:"w"`
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	Z float64 `json:"z"`
+}
+
+// quaternion of the rotation by angle radians about an axis
+func AxisAngleQuaternion(axis Point3D, angle float64) Quaternion {
+	k := axis.Normalize().Scale(math.Sin(angle / 2))
+	return Quaternion{math.Cos(angle / 2), k.X, k.Y, k.Z}
+}
+
+// Hamilton product q r, the rotation applying r then q
+func (q Quaternion) Mul(r Quaternion) Quaternion {
+	return Quaternion{
+		q.W*r.W - q.X*r.X - q.Y*r.Y - q.Z*r.Z,
+		q.W*r.X + q.X*r.W + q.Y*r.Z - q.Z*r.Y,
+		q.W*r.Y - q.X*r.Z + q.Y*r.W + q.Z*r.X,
+		q.W*r.Z + q.X*r.Y - q.Y*r.X + q.Z*r.W,
+	}
+}
+
+// conjugate of the quaternion, the inverse rotation of a unit quaternion
+func (q Quaternion) Conjugate() Quaternion {
+	return Quaternion{q.W, -q.X, -q.Y, -q.Z}
+}
+
+// quaternion scaled to unit length (zero quaternion is returned as the identity)
+func (q Quaternion) Normalize() Quaternion {
+	n := math.Sqrt(q.W*q.W + q.X*q.X + q.Y*q.Y + q.Z*q.Z)
+	if n == 0 {
+		return Quaternion{W: 1}
+	}
+	return Quaternion{q.W / n, q.X / n, q.Y / n, q.Z / n}
+}
+
+// rotates a vector by the unit quaternion
+func (q Quaternion) Rotate(v Point3D) Point3D {
+	p := q.Mul(Quaternion{0, v.X, v.Y, v.Z}).Mul(q.Conjugate())
+	return Point3D{p.X, p.Y, p.Z}
+}
+
+// rotation matrix of the quaternion
+func (q Quaternion) Matrix() Matrix3 {
+	q = q.Normalize()
+	w, x, y, z := q.W, q.X, q.Y, q.Z
+	return Matrix3{
+		{1 - 2*(y*y+z*z), 2 * (x*y - w*z), 2 * (x*z + w*y)},
+		{2 * (x*y + w*z), 1 - 2*(x*x+z*z), 2 * (y*z - w*x)},
+		{2 * (x*z - w*y), 2 * (y*z + w*x), 1 - 2*(x*x+y*y)},
+	}
+}
+
+// unit quaternion of a rotation matrix, with a non negative W
+func (m Matrix3) Quaternion() Quaternion {
+	// the largest of the diagonal combinations gives the best conditioned formula
+	trace := m[0][0] + m[1][1] + m[2][2]
+	var q Quaternion
+	switch {
+	case trace > 0:
+		s := 2 * math.Sqrt(trace+1)
+		q = Quaternion{s / 4, (m[2][1] - m[1][2]) / s, (m[0][2] - m[2][0]) / s, (m[1][0] - m[0][1]) / s}
+	case m[0][0] > m[1][1] && m[0][0] > m[2][2]:
+		s := 2 * math.Sqrt(1+m[0][0]-m[1][1]-m[2][2])
+		q = Quaternion{(m[2][1] - m[1][2]) / s, s / 4, (m[0][1] + m[1][0]) / s, (m[0][2] + m[2][0]) / s}
+	case m[1][1] > m[2][2]:
+		s := 2 * math.Sqrt(1+m[1][1]-m[0][0]-m[2][2])
+		q = Quaternion{(m[0][2] - m[2][0]) / s, (m[0][1] + m[1][0]) / s, s / 4, (m[1][2] + m[2][1]) / s}
+	default:
+		s := 2 * math.Sqrt(1+m[2][2]-m[0][0]-m[1][1])
+		q = Quaternion{(m[1][0] - m[0][1]) / s, (m[0][2] + m[2][0]) / s, (m[1][2] + m[2][1]) / s, s / 4}
+	}
+	if q.W < 0 {
+		q = Quaternion{-q.W, -q.X, -q.Y, -q.Z}
+	}
+	return q.Normalize()
+}
+
+// EulerAngles are the angles in radians of the rotations about the x (roll), y (pitch) and z (yaw) axes of the
+// fixed frame, applied in this order, so that the rotation matrix is Rz(yaw) Ry(pitch) Rx(roll)
+type EulerAngles struct {
+	Roll  float64 `json:"roll"`
+	Pitch float64 `json:"pitch"`
+	Yaw   float64 `json:"yaw"`
+}
+
+// rotation matrix of the Euler angles
+func (e EulerAngles) Matrix() Matrix3 {
+	rx := AxisAngleRotation(Point3D{1, 0, 0}, e.Roll)
+	ry := AxisAngleRotation(Point3D{0, 1, 0}, e.Pitch)
+	rz := AxisAngleRotation(Point3D{0, 0, 1}, e.Yaw)
+	return rz.Mul(ry).Mul(rx)
+}
+
+// Euler angles of a rotation matrix, with the pitch between -pi/2 and pi/2
+// at a pitch of +-pi/2 (gimbal lock) the roll is set to 0
+func (m Matrix3) EulerAngles() EulerAngles {
+	sine := math.Max(-1, math.Min(1, -m[2][0]))
+	pitch := math.Asin(sine)
+	if math.Abs(sine) > 1-1e-12 {
+		return EulerAngles{0, pitch, math.Atan2(-m[0][1], m[1][1])}
+	}
+	return EulerAngles{math.Atan2(m[2][1], m[2][2]), pitch, math.Atan2(m[1][0], m[0][0])}
+}
+
+// RigidTransform is a rotation followed by a translation, mapping a point p to Rotation p + Translation
+type RigidTransform struct {
+	Rotation    Matrix3 `json:"rotation"`
+	Translation Point3D `json:"translation"`
+}
+
+// identity transform
+func IdentityTransform() RigidTransform {
+	return RigidTransform{Rotation: Identity3()}
+}
+
+// creates the rigid transform of a 4x4 matrix
+// returns an error if the matrix is not a rotation followed by a translation
+func NewRigidTransform(matrix Matrix4) (RigidTransform, error) {
+	transform := RigidTransform{Translation: Point3D{matrix[0][3], matrix[1][3], matrix[2][3]}}
+	for a := 0; a < 3; a++ {
+		copy(transform.Rotation[a][:], matrix[a][:3])
+	}
+	if matrix[3] != [4]float64{0, 0, 0, 1} {
+		return transform, errors.New("last row of a rigid transform must be 0 0 0 1")
+	}
+	if !transform.Rotation.IsRotation() {
+		return transform, errors.New("matrix is not a rotation")
+	}
+	return transform, nil
+}
+
+// 4x4 matrix of the transform
+func (t RigidTransform) Matrix() Matrix4 {
+	matrix := Identity4()
+	for a := 0; a < 3; a++ {
+		copy(matrix[a][:3], t.Rotation[a][:])
+	}
+	matrix[0][3], matrix[1][3], matrix[2][3] = t.Translation.X, t.Translation.Y, t.Translation.Z
+	return matrix
+}
+
+// applies the transform to a point
+func (t RigidTransform) Apply(point Point3D) Point3D {
+	return t.Rotation.MulVector(point).Add(t.Translation)
+}
+
+// applies the rotation of the transform to a direction
+func (t RigidTransform) ApplyDirection(direction Point3D) Point3D {
+	return t.Rotation.MulVector(direction)
+}
+
+// transform applying t then next
+func (t RigidTransform) Then(next RigidTransform) RigidTransform {
+	return RigidTransform{
+		Rotation:    next.Rotation.Mul(t.Rotation),
+		Translation: next.Apply(t.Translation),
+	}
+}
+
+// inverse of the transform
+func (t RigidTransform) Inverse() RigidTransform {
+	rotation := t.Rotation.Transpose()
+	return RigidTransform{Rotation: rotation, Translation: rotation.MulVector(t.Translation).Scale(-1)}
+}
+
+// applies a rigid transform to the plane
+func (p *Plane3D) Transform(t RigidTransform) Plane3D {
+	// a point x' of the transformed plane is R x + T for a point x of the plane, and n . x + d = 0 becomes
+	// (R n) . x' + d - (R n) . T = 0
+	normal := t.ApplyDirection(Point3D{p.A, p.B, p.C})
+	return Plane3D{normal.X, normal.Y, normal.Z, p.D - normal.Dot(t.Translation)}
+}
+
+// applies a rigid transform to the points of the point cloud, with a worker per CPU each transforming a chunk of the
+// points
+// returns the transformed point cloud, with the attributes of the points
+func (pointCloud *PointCloud) Transform(t RigidTransform) PointCloud {
+	points := make([]Point3D, len(pointCloud.points))
+	workers := runtime.NumCPU()
+	chunkSize := (len(points) + workers - 1) / workers
+	var wg sync.WaitGroup
+	for start := 0; start < len(points); start += chunkSize {
+		end := start + chunkSize
+		if end > len(points) {
+			end = len(points)
+		}
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			for i := start; i < end; i++ {
+				points[i] = t.Apply(pointCloud.points[i])
+			}
+		}(start, end)
+	}
+	wg.Wait()
+
+	transformed := NewPointCloud(points)
+	for name, values := range pointCloud.attributes {
+		transformed.SetAttribute(name, values)
+	}
+	return transformed
+}
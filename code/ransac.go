@@ -1,6 +1,7 @@
 package code
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"os"
@@ -13,99 +14,361 @@ const DEFAULT_NUM_OF_DOMINANT_PLANES int = 3
 // additional message are printed if DEBUG is true
 var DEBUG bool = false
 
+// plane segmentation methods
+const (
+	METHOD_RANSAC         = "ransac"
+	METHOD_REGION_GROWING = "region"
+	METHOD_JLINKAGE       = "jlinkage"
+	METHOD_MANHATTAN      = "manhattan"
+)
+
+// built in shapes which can be detected
+const (
+	SHAPE_PLANE    = "plane"
+	SHAPE_SPHERE   = "sphere"
+	SHAPE_CYLINDER = "cylinder"
+	SHAPE_LINE     = "line"
+	SHAPE_CIRCLE   = "circle"
+)
+
+// samplers drawing the minimal samples of RANSAC
+const (
+	SAMPLER_UNIFORM = "uniform"
+	SAMPLER_PROSAC  = "prosac"
+	SAMPLER_NAPSAC  = "napsac"
+)
+
+// name of the point attribute holding the quality scores provided apart from the point cloud
+const DEFAULT_SCORE_ATTRIBUTE = "score"
+
+// optional parameters of a RANSAC run
+type RansacOptions struct {
+	// shape of the dominant models, SHAPE_PLANE (default), SHAPE_SPHERE, SHAPE_CYLINDER, SHAPE_LINE, SHAPE_CIRCLE
+	// or the name of a model added with RegisterModel
+	Shape string
+	// number of dominant models to be identified (0 uses DEFAULT_NUM_OF_DOMINANT_PLANES)
+	NumOfModels int
+	// radius limits of detected spheres, cylinders and circles (0 maximum radius means no limit)
+	MinRadius float64
+	MaxRadius float64
+	// number of neighbours used for normal estimation (0 uses DEFAULT_NORMAL_NEIGHBOURS)
+	NormalNeighbours int
+	// if not zero, cylinders whose axis makes an angle of more than MaxAxisAngle degrees with Axis are rejected
+	Axis         Point3D
+	MaxAxisAngle float64
+	// plane segmentation method, METHOD_RANSAC (default), METHOD_REGION_GROWING, METHOD_JLINKAGE or METHOD_MANHATTAN
+	Method string
+	// parameters of the region growing segmentation
+	RegionGrowing RegionGrowingOptions
+	// parameters of the J-linkage multi-plane extraction
+	JLinkage JLinkageOptions
+	// size of the grid cells used to split dominant planes into connected segments (0 disables splitting)
+	SegmentCellSize float64
+	// segments with fewer points are returned to the points not covered by dominant planes
+	MinSegmentSize int
+	// distance tolerance used to cluster the points not covered by dominant planes (0 disables clustering)
+	ClusterTolerance float64
+	// clusters with fewer or more points are discarded (0 maximum size means no limit)
+	MinClusterSize int
+	MaxClusterSize int
+	// sampler drawing the minimal samples, SAMPLER_UNIFORM (default), SAMPLER_PROSAC or SAMPLER_NAPSAC
+	Sampler string
+	// point attribute holding the quality scores ranking the points for SAMPLER_PROSAC (higher is better)
+	// defaults to DEFAULT_SCORE_ATTRIBUTE
+	ScoreAttribute string
+	// quality scores, one per point, or file containing them one per line, used instead of an attribute of the input file
+	Scores    []float64
+	ScoreFile string
+	// radius of the neighbourhood of the samples drawn by SAMPLER_NAPSAC
+	// (0 uses DEFAULT_NAPSAC_RADIUS_FACTOR times eps)
+	NapsacRadius float64
+	// re-estimates each detected primitive from all its supporting points
+	Refit bool
+	// locally optimizes promising hypotheses (LO-RANSAC)
+	LocalOptimization bool
+	// rejects hypotheses unlikely to beat the best so far before checking all points (SPRT)
+	EarlyRejection bool
+	// constrains the normals of the planes detected by RANSAC
+	// the maximum angle also bounds the angle between the planes and the axes of the Manhattan frame
+	Orientation OrientationConstraint
+	// frame of the planes detected by METHOD_MANHATTAN (estimated from the point cloud if nil)
+	ManhattanFrame *ManhattanFrame
+	// transform applied to the point cloud before the detection, the output being in the transformed frame (nil if none)
+	Transform *RigidTransform
+	// compute the convex and concave hulls of the dominant planes
+	Boundaries bool
+	// alpha of the concave hulls (0 computes the convex hulls only)
+	BoundaryAlpha float64
+	// save the boundary of each dominant plane to a file
+	SaveBoundaries bool
+	// compute the area, extent and density of the dominant planes
+	Metrics bool
+	// size of the grid cells counted as the area of the dominant planes (0 uses the area of their convex hulls)
+	AreaCellSize float64
+	// format of the meshes of the dominant planes triangulated from their outlines (empty saves no mesh)
+	MeshFormat string
+	// save the meshes of all the dominant planes to a single file instead of a file per plane
+	CombinedMesh bool
+	// compute the edges and corners between the dominant planes and save the edges to a file
+	Edges bool
+	// maximum distance from the intersection line of 2 planes of their points along the edge (0 uses 3 times eps)
+	EdgeDistance float64
+	// minimum angle in degrees between planes sharing an edge (0 uses DEFAULT_INTERSECTION_ANGLE)
+	IntersectionAngle float64
+	// number (1 based) of the dominant plane the heights of the points are measured from, its normal pointing to the
+	// side with the most points (0 computes no heights)
+	HeightPlane int
+	// size of the cells of the elevation grid rasterizing the heights in the frame of the plane (0 saves no grid)
+	DEMCellSize float64
+	// format of the 16-bit images of the elevation grid, DEM_IMAGE_PGM (default) or DEM_IMAGE_PNG
+	DEMImage string
+	// maximum distance between the centroid of a plane and a plane merged with it (0 disables merging)
+	MergeOffset float64
+	// maximum angle in degrees between the normals of merged planes (0 uses DEFAULT_MERGE_ANGLE)
+	MergeAngle float64
+	// relationships between the detected planes, which are then jointly refined
+	Relations []PlaneRelation
+	// if positive, the planes parallel or orthogonal within RelationAngle degrees are also related
+	RelationAngle float64
+	// if positive, eps is EpsSigmaFactor times the noise scale estimated from the point cloud
+	// instead of the eps given to the run
+	EpsSigmaFactor float64
+}
+
 // method to compute the number of iterations needed for RANSAC
-func getNumberOfIterations(confidence float64, perctangeOfPointsOnPlane float64) int {
+// the size of the random samples defaults to 3 (points defining a plane)
+func getNumberOfIterations(confidence float64, perctangeOfPointsOnPlane float64, sampleSize ...int) int {
+		// use the plane sample size if none provided
+		if len(sampleSize) == 0 {
+			sampleSize = []int{3}
+		}
 		// The number of iterations is computed as follows:
-		// n = log(1 - confidence) / log(1 - (percentageOfPointsOnPlane)^3)
+		// n = log(1 - confidence) / log(1 - (percentageOfPointsOnPlane)^s)
 		// where n is the number of iterations, confidence is the probability that
-		// at least one of the iterations will find a good model, and
+		// at least one of the iterations will find a good model,
 		// percentageOfPointsOnPlane is the percentage of points that are on the
-		// plane.
-		return int(math.Log(1 - confidence) / math.Log(1 - math.Pow(perctangeOfPointsOnPlane, 3)))
+		// plane, and s is the number of points in each sample.
+		return int(math.Log(1 - confidence) / math.Log(1 - math.Pow(perctangeOfPointsOnPlane, float64(sampleSize[0]))))
 }
 
+// identifies the plane with most supporting points among the planes computed from numOfIterations random samples
 func DominantPlaneIdentifier(numOfIterations int, pointCloud PointCloud, eps float64) Plane3DwSupport {
-	
-	// receive array containing random points for numOfIterations
-	randomPointsChan := pointCloud.TakeN(numOfIterations)
-
-	// get plane
-	plane := GetPlaneC(randomPointsChan)
-
-	// get supporting points
-	supportingPoints := pointCloud.GetSupportingPointsC(plane, eps)
-
-	// get best plane
-	bestPlane := fanIn(supportingPoints)
-	
-	return <-bestPlane
+	bestPlane, _ := DominantModelIdentifier[Plane3D](numOfIterations, pointCloud, eps, PlaneModel{})
+	return newPlane3DwSupport(bestPlane)
 }
 
 // fanIn method receives Plane3DwSupport instances from inbound channel and sends back the best plane with the most supporting points on the outbound channel
 func fanIn(supportingPointsIn <-chan Plane3DwSupport) <-chan Plane3DwSupport {
-	// outbound channel
-	bestPlaneOut := make(chan Plane3DwSupport)
-	// store best support
-	bestSupport := 0
-	// best plane
-	var bestPlane Plane3DwSupport
-	// goroutine to find the best plane
-	go func() {
-		defer close(bestPlaneOut)
-		// until we receive plane on the inbound channel
-		for plane := range supportingPointsIn {
-			// get plane from inbound channel
-			// if the plane has more supporting points than the current best plane
-			if plane.SupportSize > bestSupport {
-				// update the best support
-				bestSupport = plane.SupportSize
-				// update the best plane
-				bestPlane = plane
-			}
-		}
-		// send the best plane on the outbound channel
-		bestPlaneOut <- bestPlane
-	}()
-	// return the outbound channel
-	return bestPlaneOut
+	planes := convertC(supportingPointsIn, func(plane Plane3DwSupport) ModelWithSupport[Plane3D] {
+		return ModelWithSupport[Plane3D]{Model: plane.Plane3D, SupportSize: plane.SupportSize, SupportingPoints: plane.SupportingPoints}
+	})
+	return convertC(modelFanIn(planes, nil), newPlane3DwSupport)
+}
+
+// converts a plane detected by the generic RANSAC engine to a Plane3DwSupport
+// the empty result of the engine becomes a plane without support
+func newPlane3DwSupport(plane ModelWithSupport[Plane3D]) Plane3DwSupport {
+	if plane.SupportSize < 0 {
+		return Plane3DwSupport{}
+	}
+	return Plane3DwSupport{
+		Plane3D:          plane.Model,
+		SupportSize:      plane.SupportSize,
+		SupportingPoints: plane.SupportingPoints,
+	}
 }
 
-// method to retrieve given number of dominant planes from the point cloud
+// method to retrieve given number of dominant planes of the model from the point cloud with the engine configured by
+// engine options
 // returns an array containing dominant planes and the point cloud without the points belonging to the dominant planes
-func getDominantPlanes(numOfIterations int, pointCloud PointCloud, eps float64, numOfDominantPlanes ...int) ([]Plane3DwSupport, PointCloud) {
-		// store the dominant planes
-		dominantPlanes := []Plane3DwSupport{}
+func getDominantPlanes(numOfIterations int, pointCloud PointCloud, eps float64, model Model[Plane3D], engine EngineOptions, numOfDominantPlanes ...int) ([]Plane3DwSupport, PointCloud) {
 		// if the number of dominant planes is not specified, set it to the default value
 		if len(numOfDominantPlanes) == 0 {
 				numOfDominantPlanes = []int{DEFAULT_NUM_OF_DOMINANT_PLANES}
 		}
-		// store the point cloud
-		cloud := pointCloud
-		// iterate for the number of dominant planes to be identified
-		for i := 0; i < numOfDominantPlanes[0]; i++ {
-				// identify the dominant plane from given point cloud
-				dominantPlane := DominantPlaneIdentifier(numOfIterations, cloud, eps)
-				// append the dominant plane to the array of dominant planes
-				dominantPlanes = append(dominantPlanes, dominantPlane)
-				// remove the points on the dominant plane from the point cloud
-				cloud = cloud.RemovePlane(&dominantPlane.Plane3D, eps)
+		// identify the dominant planes with the generic RANSAC engine
+		planes, cloud := Engine[Plane3D]{model, engine}.GetDominantModels(numOfIterations, pointCloud, eps, numOfDominantPlanes[0])
+		// store the dominant planes
+		dominantPlanes := []Plane3DwSupport{}
+		for _, plane := range planes {
+				dominantPlanes = append(dominantPlanes, newPlane3DwSupport(plane))
 		}
 
 		// return the array of dominant planes and the points cloud without the points belonging to the dominant planes
 		return dominantPlanes, cloud
 }
 
+// method to retrieve the dominant planes with the segmentation method selected in options
+// the methods other than RANSAC cannot reject hypotheses violating the orientation constraint, so that the planes
+// they detect which violate it are dropped, their points joining the remaining points
+// METHOD_MANHATTAN needs the Manhattan frame of options, given by setManhattanFrame
+// returns an array containing dominant planes and the point cloud without the points belonging to the dominant planes
+func detectDominantPlanes(numOfIterations int, pointCloud PointCloud, eps float64, options RansacOptions, engine EngineOptions) ([]Plane3DwSupport, PointCloud, error) {
+	// if the number of dominant planes is not specified, set it to the default value
+	numOfPlanes := options.NumOfModels
+	if numOfPlanes <= 0 {
+		numOfPlanes = DEFAULT_NUM_OF_DOMINANT_PLANES
+	}
+	var planes []Plane3DwSupport
+	var cloud PointCloud
+	switch options.Method {
+	case METHOD_REGION_GROWING:
+		regionGrowing := options.RegionGrowing
+		if regionGrowing.Neighbours <= 0 {
+			regionGrowing.Neighbours = options.NormalNeighbours
+		}
+		planes, cloud = getRegionGrowingPlanes(pointCloud, regionGrowing, numOfPlanes)
+	case METHOD_JLINKAGE:
+		planes, cloud = getJLinkagePlanes(pointCloud, eps, options.JLinkage, numOfPlanes)
+	case METHOD_MANHATTAN:
+		if options.ManhattanFrame == nil {
+			return nil, pointCloud, errors.New("no Manhattan frame")
+		}
+		planes, cloud = getDominantPlanes(numOfIterations, pointCloud, eps, ManhattanModel{*options.ManhattanFrame, options.Orientation.MaxAngle}, engine, numOfPlanes)
+	default:
+		planes, cloud = getDominantPlanes(numOfIterations, pointCloud, eps, PlaneModel{options.Orientation}, engine, numOfPlanes)
+		return planes, cloud, nil
+	}
+	kept := filterOrientedPlanes(planes, options.Orientation)
+	if len(kept) < len(planes) {
+		planes, cloud = kept, getRemainingPoints(&pointCloud, kept)
+	}
+	return planes, cloud, nil
+}
+
+// method to estimate the Manhattan frame of the point cloud for METHOD_MANHATTAN if options do not give it
+// returns an error if the frame cannot be estimated
+func setManhattanFrame(pointCloud *PointCloud, options *RansacOptions) error {
+	if options.Method != METHOD_MANHATTAN || options.ManhattanFrame != nil {
+		return nil
+	}
+	frame, err := pointCloud.EstimateManhattanFrame(options.NormalNeighbours, options.Orientation.MaxAngle)
+	if err != nil {
+		return err
+	}
+	options.ManhattanFrame = &frame
+	return nil
+}
+
+// method to drop the planes violating the orientation constraint
+// returns the planes satisfying it
+func filterOrientedPlanes(planes []Plane3DwSupport, orientation OrientationConstraint) []Plane3DwSupport {
+	if !orientation.IsSet() {
+		return planes
+	}
+	kept := []Plane3DwSupport{}
+	for _, plane := range planes {
+		if orientation.Accepts(plane.Plane3D) {
+			kept = append(kept, plane)
+		}
+	}
+	if len(kept) < len(planes) {
+		fmt.Printf("%d planes violating the orientation constraint dropped\n", len(planes)-len(kept))
+	}
+	return kept
+}
+
+// method to get the points of the point cloud supporting none of the planes, along with their attributes
+func getRemainingPoints(pointCloud *PointCloud, planes []Plane3DwSupport) PointCloud {
+	points := []Point3D{}
+	for _, plane := range planes {
+		points = append(points, plane.SupportingPoints...)
+	}
+	return pointCloud.withoutPoints(points)
+}
+
+// method to get the inlier threshold of the run
+// returns eps and a zero noise scale, or the multiple of the noise scale estimated from the point cloud requested
+// in options and the noise scale
+// returns an error if the noise cannot be estimated and eps is not positive
+func getEps(pointCloud *PointCloud, eps float64, options RansacOptions) (float64, float64, error) {
+	if options.EpsSigmaFactor <= 0 {
+		return eps, 0, nil
+	}
+	sigma := pointCloud.EstimateNoise(options.NormalNeighbours)
+	eps, err := getNoiseEps(sigma, options.EpsSigmaFactor, eps)
+	return eps, sigma, err
+}
+
+// method to configure the RANSAC engine from the options of the run
+func getEngineOptions(options RansacOptions, confidence, eps float64) EngineOptions {
+	engine := EngineOptions{Refit: options.Refit, LocalOptimization: options.LocalOptimization, EarlyRejection: options.EarlyRejection}
+	if options.LocalOptimization || options.EarlyRejection {
+		engine.Stats = &EngineStats{}
+	}
+	switch options.Sampler {
+	case SAMPLER_PROSAC:
+		engine.Sampler = ProsacSamplerFactory(getScoreAttribute(options), confidence)
+	case SAMPLER_NAPSAC:
+		radius := options.NapsacRadius
+		if radius <= 0 {
+			radius = DEFAULT_NAPSAC_RADIUS_FACTOR * eps
+		}
+		engine.Sampler = NapsacSamplerFactory(radius)
+	}
+	return engine
+}
+
+// method to get the name of the point attribute holding the quality scores
+func getScoreAttribute(options RansacOptions) string {
+	if options.ScoreAttribute == "" {
+		return DEFAULT_SCORE_ATTRIBUTE
+	}
+	return options.ScoreAttribute
+}
+
+// method to attach the quality scores provided in options to the point cloud
+// a warning is printed if the point cloud has no scores, its points then being sampled uniformly
+// returns an error if the scores do not match the points
+func setScores(pointCloud *PointCloud, options RansacOptions) error {
+	scores := options.Scores
+	if options.ScoreFile != "" {
+		values, err := readValues(options.ScoreFile)
+		if err != nil {
+			return err
+		}
+		scores = values
+	}
+	attribute := getScoreAttribute(options)
+	if scores != nil {
+		return pointCloud.SetAttribute(attribute, scores)
+	}
+	if _, ok := pointCloud.GetAttribute(attribute); !ok {
+		fmt.Printf("Warning: no %s attribute to rank the points, sampling uniformly\n", attribute)
+	}
+	return nil
+}
+
 // method to get the output filename
-func getOutputFilename(filename string) (file string) {
+// the suffix identifying the kind of output defaults to "_p" (dominant planes)
+func getOutputFilename(filename string, suffix ...string) (file string) {
+	// use the dominant planes suffix if none provided
+	if len(suffix) == 0 {
+		suffix = []string{"_p"}
+	}
 	// remove substring '.xyz' from filename if it exists, and dd output path
 	// remove "/data/datasets/" from filename if it exists
 	file = strings.Replace(filename, "data/datasets/", "", -1)
-	file = "data/output/" + strings.Replace(file, ".xyz", "", -1) + "_p"
+	file = "data/output/" + strings.Replace(file, ".xyz", "", -1) + suffix[0]
 	return
 }
 
-func RANSAC(filename string, confidence, percentageOfPointsOnPlane, eps float64) {
+func RANSAC(filename string, confidence, percentageOfPointsOnPlane, eps float64, options ...RansacOptions) {
 	fmt.Println("Initiating RANSAC")
+	// use default options if none provided
+	if len(options) == 0 {
+		options = []RansacOptions{{}}
+	}
+	if options[0].Method == "" {
+		options[0].Method = METHOD_RANSAC
+	}
+	if options[0].Shape == "" {
+		options[0].Shape = SHAPE_PLANE
+	}
+	if options[0].Sampler == "" {
+		options[0].Sampler = SAMPLER_UNIFORM
+	}
+
 	// get the PointCloud
 	pointCloud, err := readXYZ(filename)
 	// if error extracting point cloud, print error and exit
@@ -117,25 +380,178 @@ func RANSAC(filename string, confidence, percentageOfPointsOnPlane, eps float64)
 	fmt.Println("Point Cloud extracted successfully")
 	fmt.Println("Point Cloud size: ", len(pointCloud.points))
 
-	// calculate number of iterations
-	numOfIterations := getNumberOfIterations(confidence, percentageOfPointsOnPlane)
+	// move the point cloud to the frame it is segmented in
+	if options[0].Transform != nil {
+		pointCloud = pointCloud.Transform(*options[0].Transform)
+		fmt.Println("Point Cloud transformed")
+	}
+
+	// estimate eps from the noise of the point cloud if requested
+	eps, sigma, err := getEps(&pointCloud, eps, options[0])
+	if err != nil {
+		fmt.Println("Unable to estimate epsilon", err)
+		os.Exit(1)
+	}
+	if sigma > 0 {
+		fmt.Println("Estimated noise: ", sigma)
+		fmt.Println("Estimated epsilon: ", eps)
+	}
+
+	// attach the quality scores ranking the points
+	if options[0].Sampler == SAMPLER_PROSAC {
+		err = setScores(&pointCloud, options[0])
+		if err != nil {
+			fmt.Println("Unable to get quality scores", err)
+			os.Exit(1)
+		}
+	}
+	engine := getEngineOptions(options[0], confidence, eps)
+
+	// get the detector of the shape
+	factory, ok := GetModelFactory(options[0].Shape)
+	if !ok {
+		fmt.Println("Unknown shape", options[0].Shape)
+		os.Exit(1)
+	}
+	detector := factory(options[0], pointCloud)
+
+	// calculate number of iterations for the size of the samples of the shape
+	numOfIterations := getNumberOfIterations(confidence, percentageOfPointsOnPlane, detector.SampleSize())
 	fmt.Println("Number of iterations: ", numOfIterations)
 
+	// report of the run
+	report := RunReport{
+		Input:                     filename,
+		Method:                    options[0].Method,
+		Shape:                     options[0].Shape,
+		Sampler:                   options[0].Sampler,
+		Confidence:                confidence,
+		PercentageOfPointsOnPlane: percentageOfPointsOnPlane,
+		Eps:                       eps,
+		Iterations:                numOfIterations,
+		TotalPoints:               len(pointCloud.points),
+	}
+	if options[0].Sampler == SAMPLER_PROSAC {
+		report.ScoreAttribute = getScoreAttribute(options[0])
+	}
+	if sigma > 0 {
+		report.EstimatedNoise = sigma
+		report.EpsSigmaFactor = options[0].EpsSigmaFactor
+	}
+
+	// get the dominant shapes, save them to files, and get the point cloud without the points belonging to them
+	var cloud PointCloud
+	if options[0].Shape == SHAPE_PLANE {
+		cloud = ransacPlanes(filename, numOfIterations, pointCloud, eps, options[0], engine, &report)
+	} else {
+		cloud = ransacShapes(filename, numOfIterations, pointCloud, eps, detector, options[0], engine, &report)
+	}
+
+	// extract the clusters of the points not covered by dominant shapes and save each cluster to a file
+	if options[0].ClusterTolerance > 0 {
+		clusters := cloud.EuclideanClusters(options[0].ClusterTolerance, options[0].MinClusterSize, options[0].MaxClusterSize)
+		fmt.Println("Number of clusters: ", len(clusters))
+		clusterFilename := getOutputFilename(filename, "_c")
+		for i, cluster := range clusters {
+			file := clusterFilename + strconv.Itoa(i+1) + ".xyz"
+			err := saveXYZ(file, cluster.Points)
+			// if error saving cluster, print error and exit
+			if err != nil {
+				fmt.Println("Unable to save cluster", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Cluster %d: %v \n", i+1, cluster)
+			report.Clusters = append(report.Clusters, newClusterReport(cluster, file))
+		}
+	}
+
+	// report the work of the optional stages of the RANSAC engine
+	if engine.Stats != nil {
+		if options[0].LocalOptimization {
+			fmt.Printf("Local optimizations: %d, improved: %d, supporting points gained: %d\n", engine.Stats.LocalOptimizations, engine.Stats.LocalImprovements, engine.Stats.LocalSupportGain)
+		}
+		if options[0].EarlyRejection {
+			fmt.Printf("Hypotheses verified: %d, rejected early: %d, points checked: %d\n", engine.Stats.VerifiedHypotheses, engine.Stats.RejectedHypotheses, engine.Stats.CheckedPoints)
+		}
+		report.EngineStats = engine.Stats
+	}
+
+	fmt.Printf("Total number of points covered by dominant %ss: %d\n", options[0].Shape, len(pointCloud.points)-len(cloud.points))
+	fmt.Printf("Total number of points not covered by dominant %ss: %d\n", options[0].Shape, len(cloud.points))
+	fmt.Println("Total number of points: ", len(pointCloud.points))
+
+	// save the report of the run
+	err = saveReport(getOutputFilename(filename, "_report.json"), report)
+	if err != nil {
+		fmt.Println("Unable to save report", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("Program completed successfully :)")
+}
+
+// method to detect the dominant planes and save them to files
+// returns the point cloud without the points belonging to the dominant planes
+func ransacPlanes(filename string, numOfIterations int, pointCloud PointCloud, eps float64, options RansacOptions, engine EngineOptions, report *RunReport) PointCloud {
+	// estimate the Manhattan frame of the planes
+	err := setManhattanFrame(&pointCloud, &options)
+	if err != nil {
+		fmt.Println("Unable to estimate Manhattan frame", err)
+		os.Exit(1)
+	}
+	if options.ManhattanFrame != nil {
+		fmt.Println("Manhattan frame axes: ", options.ManhattanFrame.Axes)
+		report.ManhattanFrame = newManhattanFrameReport(*options.ManhattanFrame)
+	}
+
 	// get the dominant planes and the point cloud without the points belonging to the dominant planes
-	dominantPlanes, cloud := getDominantPlanes(numOfIterations, pointCloud, eps)
+	dominantPlanes, cloud, err := detectDominantPlanes(numOfIterations, pointCloud, eps, options, engine)
+	if err != nil {
+		fmt.Println("Unable to detect dominant planes", err)
+		os.Exit(1)
+	}
 
 	fmt.Println("RANSAC completed")
 	fmt.Println("Number of dominant planes: ", len(dominantPlanes))
 
-	// size of points covered by dominant planes
-	dominantPlanesSize := 0
+	// merge the planes which are the same surface
+	if options.MergeOffset > 0 {
+		merged, merges := MergePlanes(dominantPlanes, options.MergeAngle, options.MergeOffset)
+		dominantPlanes = merged
+		for _, merge := range merges {
+			fmt.Printf("Planes %v merged into plane %d\n", merge.Merged, merge.Plane)
+		}
+		report.Merges = merges
+		fmt.Println("Number of merged planes: ", len(dominantPlanes))
+	}
+
+	// jointly refine the planes under their relationships, before they are split so that the relationships refer to
+	// the detected planes
+	if len(options.Relations) > 0 || options.RelationAngle > 0 {
+		err := refineRelatedPlanes(dominantPlanes, eps, options, report)
+		if err != nil {
+			fmt.Println("Unable to refine planes", err)
+			os.Exit(1)
+		}
+		cloud = getRemainingPoints(&pointCloud, dominantPlanes)
+	}
+
+	// split the dominant planes into connected segments
+	if options.SegmentCellSize > 0 {
+		segments, _ := splitDominantPlanes(dominantPlanes, options.SegmentCellSize, options.MinSegmentSize)
+		dominantPlanes = segments
+		cloud = getRemainingPoints(&pointCloud, dominantPlanes)
+		fmt.Println("Number of planar segments: ", len(dominantPlanes))
+	}
 
 	// get the output filename
-	filename = getOutputFilename(filename)
+	outputFilename := getOutputFilename(filename)
 
 	// save each dominant plane to a file
+	meshes := []Mesh{}
 	for i, plane := range dominantPlanes {
-		err := saveXYZ(filename + strconv.Itoa(i+1) + ".xyz", plane.SupportingPoints)
+		planeFilename := outputFilename + strconv.Itoa(i+1) + ".xyz"
+		err := saveXYZ(planeFilename, plane.SupportingPoints)
 		// if error saving dominant plane, print error and exit
 		if err != nil {
 			fmt.Println("Unable to save dominant plane", err)
@@ -143,22 +559,218 @@ func RANSAC(filename string, confidence, percentageOfPointsOnPlane, eps float64)
 		}
 		// print size of each dominant plane
 		fmt.Printf("Dominant plane %d size: %d points \n", i+1, plane.SupportSize)
-		// update the size of points covered by dominant planes
-		dominantPlanesSize += plane.SupportSize
+
+		// surface covered by the plane
+		if options.Metrics {
+			metrics := dominantPlanes[i].SetMetrics(options.AreaCellSize)
+			fmt.Printf("Dominant plane %d area: %f, extent: %f x %f\n", i+1, metrics.Area, metrics.Rectangle.Length, metrics.Rectangle.Width)
+			plane = dominantPlanes[i]
+		}
+		planeReport := newPlaneReport(plane, planeFilename)
+
+		// outline of the plane
+		if options.Boundaries || options.SaveBoundaries {
+			boundary := plane.GetBoundary(options.BoundaryAlpha)
+			planeReport.Boundary = &boundary
+			if options.SaveBoundaries {
+				planeReport.BoundaryFile = outputFilename + strconv.Itoa(i+1) + "_boundary.xyz"
+				err := saveXYZ(planeReport.BoundaryFile, boundary.getOutline())
+				if err != nil {
+					fmt.Println("Unable to save boundary", err)
+					os.Exit(1)
+				}
+			}
+		}
+		// triangulated surface of the plane
+		if options.MeshFormat != "" {
+			mesh := plane.GetMesh("plane"+strconv.Itoa(i+1), options.BoundaryAlpha)
+			meshes = append(meshes, mesh)
+			if !options.CombinedMesh {
+				planeReport.MeshFile = outputFilename + strconv.Itoa(i+1) + GetMeshExtension(options.MeshFormat)
+				err := SaveMeshes(planeReport.MeshFile, []Mesh{mesh}, options.MeshFormat)
+				if err != nil {
+					fmt.Println("Unable to save mesh", err)
+					os.Exit(1)
+				}
+			}
+		}
+		report.Planes = append(report.Planes, planeReport)
+	}
+	if options.MeshFormat != "" && options.CombinedMesh {
+		report.MeshFile = getOutputFilename(filename, "_mesh"+GetMeshExtension(options.MeshFormat))
+		err := SaveMeshes(report.MeshFile, meshes, options.MeshFormat)
+		if err != nil {
+			fmt.Println("Unable to save mesh", err)
+			os.Exit(1)
+		}
 	}
 
 	fmt.Println("Dominant planes saved successfully")
 
+	// edges and corners between the dominant planes
+	if options.Edges {
+		maxDistance := options.EdgeDistance
+		if maxDistance <= 0 {
+			maxDistance = 3 * eps
+		}
+		report.Edges, report.Corners = GetPlaneEdges(dominantPlanes, maxDistance, options.IntersectionAngle)
+		fmt.Println("Number of edges: ", len(report.Edges))
+		fmt.Println("Number of corners: ", len(report.Corners))
+		segments := []Line3D{}
+		for _, edge := range report.Edges {
+			segments = append(segments, edge.Segment)
+		}
+		report.EdgesFile = getOutputFilename(filename, "_edges.obj")
+		err := saveSegmentsOBJ(report.EdgesFile, segments)
+		if err != nil {
+			fmt.Println("Unable to save edges", err)
+			os.Exit(1)
+		}
+	}
+
+	// heights of the points above a dominant plane and their elevation grid
+	if options.HeightPlane > 0 {
+		err := saveElevation(filename, &pointCloud, dominantPlanes, eps, options, report)
+		if err != nil {
+			fmt.Println("Unable to save heights", err)
+			os.Exit(1)
+		}
+	}
+
 	// save the point cloud without the points belonging to the dominant planes to a file
-	saveXYZ(filename + "0.xyz", cloud.points)
+	report.RemainderFile = outputFilename + "0.xyz"
+	report.RemainingPoints = len(cloud.points)
+	saveXYZ(report.RemainderFile, cloud.points)
 
 	fmt.Println("Point cloud without dominant planes saved successfully")
 
-	fmt.Println("Total number of points covered by dominant planes: ", dominantPlanesSize)
-	fmt.Println("Total number of points not covered by dominant planes: ", len(cloud.points))
-	fmt.Println("Total number of points: ", len(pointCloud.points))
+	return cloud
+}
 
-	fmt.Println("Program completed successfully :)")
+// method to refine the planes under the relationships declared in options and those detected within the relation angle
+// the planes are replaced by the refined ones, supported by their former supporting points within eps of them, and
+// the relationships are added to the report
+func refineRelatedPlanes(dominantPlanes []Plane3DwSupport, eps float64, options RansacOptions, report *RunReport) error {
+	relations := append([]PlaneRelation{}, options.Relations...)
+	if options.RelationAngle > 0 {
+		planes := []Plane3D{}
+		for _, plane := range dominantPlanes {
+			planes = append(planes, plane.Plane3D)
+		}
+		relations = append(relations, DetectPlaneRelations(planes, options.RelationAngle)...)
+	}
+	// the declared relationships come first, so that they are kept over the detected ones
+	relations = uniqueRelations(relations)
+
+	refined, err := RefinePlanes(dominantPlanes, relations)
+	if err != nil {
+		return err
+	}
+	for _, relation := range relations {
+		relationReport := newRelationReport(relation, dominantPlanes[relation.First-1].Plane3D, dominantPlanes[relation.Second-1].Plane3D, refined[relation.First-1], refined[relation.Second-1])
+		fmt.Printf("Relation %v: angle %f -> %f degrees\n", relation, relationReport.AngleBefore, relationReport.AngleAfter)
+		report.Relations = append(report.Relations, relationReport)
+	}
+	for i := range dominantPlanes {
+		supportingPoints := []Point3D{}
+		for _, point := range dominantPlanes[i].SupportingPoints {
+			if refined[i].GetDistance(&point) <= eps {
+				supportingPoints = append(supportingPoints, point)
+			}
+		}
+		dominantPlanes[i] = Plane3DwSupport{Plane3D: refined[i], SupportSize: len(supportingPoints), SupportingPoints: supportingPoints}
+	}
+	return nil
+}
+
+// method to save the heights of the points above the dominant plane chosen in options, and the ASCII grids and
+// images of the minimum, maximum and mean heights of the cells of their elevation grid
+// the report is given the files and the extent of the grid
+func saveElevation(filename string, pointCloud *PointCloud, dominantPlanes []Plane3DwSupport, eps float64, options RansacOptions, report *RunReport) error {
+	if options.HeightPlane > len(dominantPlanes) {
+		return fmt.Errorf("no dominant plane %d among %d planes", options.HeightPlane, len(dominantPlanes))
+	}
+	plane := GetUpwardPlane(dominantPlanes[options.HeightPlane-1].Plane3D, pointCloud, eps)
+	heights := pointCloud.SetHeightAttribute(plane, DEFAULT_HEIGHT_ATTRIBUTE)
+	elevation := &ElevationReport{Plane: options.HeightPlane, Normal: plane.GetUnitNormal()}
+	elevation.MinHeight, elevation.MaxHeight = getLayerRange(heights)
+	fmt.Printf("Heights above plane %d: %f to %f\n", options.HeightPlane, elevation.MinHeight, elevation.MaxHeight)
+	report.Elevation = elevation
+
+	elevation.HeightsFile = getOutputFilename(filename, "_heights.xyz")
+	err := saveXYZAttributes(elevation.HeightsFile, pointCloud, DEFAULT_HEIGHT_ATTRIBUTE)
+	if err != nil || options.DEMCellSize <= 0 {
+		return err
+	}
+
+	grid, err := GetElevationGrid(pointCloud, plane, options.DEMCellSize)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Elevation grid: %d x %d cells\n", grid.Columns, grid.Rows)
+	elevation.CellSize, elevation.Columns, elevation.Rows = grid.CellSize, grid.Columns, grid.Rows
+	origin := grid.Origin()
+	elevation.Origin, elevation.U, elevation.V = &origin, &grid.Frame.U, &grid.Frame.V
+
+	format := options.DEMImage
+	if format == "" {
+		format = DEM_IMAGE_PGM
+	}
+	for _, stat := range []string{DEM_STAT_MIN, DEM_STAT_MAX, DEM_STAT_MEAN} {
+		values, _ := grid.Layer(stat)
+		layer := ElevationLayerReport{Stat: stat}
+		layer.GridFile = getOutputFilename(filename, "_dem_"+stat+".asc")
+		err := SaveASCIIGrid(layer.GridFile, grid, values)
+		if err != nil {
+			return err
+		}
+		layer.ImageFile = getOutputFilename(filename, "_dem_"+stat+"."+format)
+		layer.ImageMin, layer.ImageMax, err = SaveGridImage(layer.ImageFile, grid, values, format)
+		if err != nil {
+			return err
+		}
+		elevation.Layers = append(elevation.Layers, layer)
+	}
+	return nil
+}
+
+// method to detect the dominant shapes other than planes and save them to files
+// returns the point cloud without the points belonging to the dominant shapes
+func ransacShapes(filename string, numOfIterations int, pointCloud PointCloud, eps float64, detector ModelDetector, options RansacOptions, engine EngineOptions, report *RunReport) PointCloud {
+	// if the number of dominant shapes is not specified, set it to the default value
+	numOfShapes := options.NumOfModels
+	if numOfShapes <= 0 {
+		numOfShapes = DEFAULT_NUM_OF_DOMINANT_PLANES
+	}
+
+	// get the dominant shapes and the point cloud without the points belonging to the dominant shapes
+	dominantShapes, cloud := detector.Detect(numOfIterations, pointCloud, eps, numOfShapes, engine)
+
+	fmt.Println("RANSAC completed")
+	fmt.Printf("Number of dominant %ss: %d\n", options.Shape, len(dominantShapes))
+
+	// output files are named after the shape
+	outputFilename := getOutputFilename(filename, "_"+options.Shape)
+
+	// save each dominant shape to a file
+	for i, shape := range dominantShapes {
+		shapeFilename := outputFilename + strconv.Itoa(i+1) + ".xyz"
+		err := saveXYZ(shapeFilename, shape.SupportingPoints)
+		// if error saving dominant shape, print error and exit
+		if err != nil {
+			fmt.Println("Unable to save dominant "+options.Shape, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Dominant %s %d: %v, size: %d points \n", options.Shape, i+1, shape.Model, shape.SupportSize)
+		report.Shapes = append(report.Shapes, newShapeReport(shape, shapeFilename))
+	}
+
+	// save the point cloud without the points belonging to the dominant shapes to a file
+	report.RemainderFile = outputFilename + "0.xyz"
+	report.RemainingPoints = len(cloud.points)
+	saveXYZ(report.RemainderFile, cloud.points)
+
+	return cloud
 }
 
 // method to print messages when DEBUG mode is on
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/pranav-kural/ransac-golang/code"
 	"github.com/pranav-kural/ransac-golang/test"
@@ -37,6 +40,235 @@ func parseArguments(filename string, confidence string, percentageOfPointsOnPlan
 	return filename, conf, per, e, nil
 }
 
+// method to parse a vector given as comma separated coordinates "x,y,z"
+func parseVector(s string) (code.Point3D, error) {
+	coordinates := strings.Split(s, ",")
+	if len(coordinates) != 3 {
+		return code.Point3D{}, fmt.Errorf("invalid vector: %s", s)
+	}
+	values := [3]float64{}
+	for i, coordinate := range coordinates {
+		value, err := strconv.ParseFloat(strings.TrimSpace(coordinate), 64)
+		if err != nil {
+			return code.Point3D{}, err
+		}
+		values[i] = value
+	}
+	return code.Point3D{X: values[0], Y: values[1], Z: values[2]}, nil
+}
+
+// method to parse a relationship between 2 planes given as "kind:first:second[:distance]"
+func parseRelation(s string) (code.PlaneRelation, error) {
+	fields := strings.Split(s, ":")
+	if len(fields) != 3 && len(fields) != 4 {
+		return code.PlaneRelation{}, fmt.Errorf("invalid relation: %s", s)
+	}
+	relation := code.PlaneRelation{Kind: fields[0]}
+	if relation.Kind != code.RELATION_PARALLEL && relation.Kind != code.RELATION_ORTHOGONAL && relation.Kind != code.RELATION_DISTANCE {
+		return relation, fmt.Errorf("unknown relation: %s", relation.Kind)
+	}
+	if (relation.Kind == code.RELATION_DISTANCE) != (len(fields) == 4) {
+		return relation, fmt.Errorf("only distance relations have a distance: %s", s)
+	}
+	var err error
+	if relation.First, err = strconv.Atoi(fields[1]); err != nil {
+		return relation, err
+	}
+	if relation.Second, err = strconv.Atoi(fields[2]); err != nil {
+		return relation, err
+	}
+	if len(fields) == 4 {
+		if relation.Distance, err = strconv.ParseFloat(fields[3], 64); err != nil {
+			return relation, err
+		}
+	}
+	return relation, nil
+}
+
+// method to parse the optional command line flags following the positional arguments
+func parseOptions(args []string) (code.RansacOptions, error) {
+	options := code.RansacOptions{}
+	flags := flag.NewFlagSet("ransac", flag.ContinueOnError)
+	flags.StringVar(&options.Shape, "shape", code.SHAPE_PLANE, "shape of the dominant models: plane, sphere, cylinder, line or circle")
+	flags.IntVar(&options.NumOfModels, "n", code.DEFAULT_NUM_OF_DOMINANT_PLANES, "number of dominant models to be identified")
+	flags.Float64Var(&options.MinRadius, "min-radius", 0, "minimum radius of detected spheres, cylinders and circles")
+	flags.Float64Var(&options.MaxRadius, "max-radius", 0, "maximum radius of detected spheres, cylinders and circles (0 means no limit)")
+	flags.Func("axis", "direction \"x,y,z\" constraining the axis of detected cylinders", func(s string) (err error) {
+		options.Axis, err = parseVector(s)
+		return err
+	})
+	flags.Float64Var(&options.MaxAxisAngle, "axis-angle", 10, "maximum angle in degrees between the axis of detected cylinders and the -axis direction")
+	flags.Func("plane-axis", "direction \"x,y,z\" constraining the normal of detected planes", func(s string) (err error) {
+		options.Orientation.Axis, err = parseVector(s)
+		return err
+	})
+	flags.BoolVar(&options.Orientation.Perpendicular, "plane-perpendicular", false, "the normal of detected planes must be perpendicular to -plane-axis instead of parallel to it")
+	flags.Float64Var(&options.Orientation.MaxAngle, "plane-angle", code.DEFAULT_ORIENTATION_ANGLE, "maximum angle in degrees between the normal of detected planes and its constrained direction or Manhattan frame axis")
+	flags.StringVar(&options.Method, "method", code.METHOD_RANSAC, "plane segmentation method: ransac, region (region growing), jlinkage (simultaneous extraction) or manhattan (planes along 3 orthogonal directions)")
+	flags.BoolVar(&options.Metrics, "metrics", false, "compute the area, oriented bounding rectangle, centroid and point density of the dominant planes, saved in the report")
+	flags.Float64Var(&options.AreaCellSize, "area-cell", 0, "size of the grid cells counted as the area of the dominant planes (0 uses the area of their convex hulls)")
+	flags.StringVar(&options.MeshFormat, "mesh", "", "save the dominant planes as meshes triangulated from their outlines: obj, stl, stl-binary or ply")
+	flags.BoolVar(&options.CombinedMesh, "mesh-combined", false, "save the meshes of all the dominant planes to a single _mesh file, each plane being a group, solid or face property")
+	flags.BoolVar(&options.Edges, "edges", false, "compute the edges and corners between the dominant planes, and save the edges as line segments to an _edges.obj file")
+	flags.Float64Var(&options.EdgeDistance, "edge-distance", 0, "maximum distance from the intersection line of 2 planes of their points along the edge (0 uses 3 times eps)")
+	flags.Float64Var(&options.IntersectionAngle, "edge-angle", code.DEFAULT_INTERSECTION_ANGLE, "minimum angle in degrees between planes sharing an edge")
+	flags.IntVar(&options.HeightPlane, "height-plane", 0, "save the heights of the points above given dominant plane (1 based), up being the side with the most points, to a _heights.xyz file")
+	flags.Float64Var(&options.DEMCellSize, "dem-cell", 0, "rasterize the heights above the -height-plane onto a grid of cells of given size, saving the min, max and mean heights as _dem ASCII grids and images")
+	flags.StringVar(&options.DEMImage, "dem-image", code.DEM_IMAGE_PGM, "format of the 16-bit images of the elevation grid: pgm or png")
+	flags.BoolVar(&options.Boundaries, "boundary", false, "compute the convex and concave hulls of the dominant planes, saved in the report")
+	flags.Float64Var(&options.BoundaryAlpha, "alpha", 0, "alpha of the concave hulls, gaps wider than about twice alpha being outside of the planes (0 computes the convex hulls only)")
+	flags.BoolVar(&options.SaveBoundaries, "save-boundary", false, "save the outline of each dominant plane as a _boundary.xyz file of ordered vertices")
+	flags.Float64Var(&options.MergeOffset, "merge-offset", 0, "merge the planes whose centroids are within given distance of each other's plane (0 disables)")
+	flags.Float64Var(&options.MergeAngle, "merge-angle", code.DEFAULT_MERGE_ANGLE, "maximum angle in degrees between the normals of merged planes")
+	flags.Func("relate", "relationship \"kind:i:j[:distance]\" between planes i and j, kind being parallel, orthogonal or distance (repeatable)", func(s string) error {
+		relation, err := parseRelation(s)
+		options.Relations = append(options.Relations, relation)
+		return err
+	})
+	flags.Float64Var(&options.RelationAngle, "auto-relate", 0, "relate the planes parallel or orthogonal within given angle in degrees (0 disables)")
+	flags.IntVar(&options.NormalNeighbours, "normal-k", code.DEFAULT_NORMAL_NEIGHBOURS, "number of neighbours used for normal estimation")
+	flags.Float64Var(&options.RegionGrowing.AngleThreshold, "angle", code.DEFAULT_ANGLE_THRESHOLD, "region growing: maximum angle in degrees between neighbouring normals")
+	flags.Float64Var(&options.RegionGrowing.CurvatureThreshold, "curvature", code.DEFAULT_CURVATURE_THRESHOLD, "region growing: maximum curvature of seed points")
+	flags.IntVar(&options.RegionGrowing.MinRegionSize, "min-region", 0, "region growing: minimum number of points of a region")
+	flags.IntVar(&options.JLinkage.Hypotheses, "hypotheses", code.DEFAULT_JLINKAGE_HYPOTHESES, "jlinkage: number of plane hypotheses")
+	flags.IntVar(&options.JLinkage.Points, "linkage-points", code.DEFAULT_JLINKAGE_POINTS, "jlinkage: number of points clustered by their preferred hypotheses")
+	flags.Float64Var(&options.SegmentCellSize, "segment-cell", 0, "grid cell size used to split dominant planes into connected segments (0 disables)")
+	flags.IntVar(&options.MinSegmentSize, "min-segment", 0, "minimum number of points of a planar segment")
+	flags.Float64Var(&options.ClusterTolerance, "cluster-tol", 0, "distance tolerance used to cluster the remaining points (0 disables)")
+	flags.IntVar(&options.MinClusterSize, "min-cluster", 1, "minimum number of points of a cluster")
+	flags.IntVar(&options.MaxClusterSize, "max-cluster", 0, "maximum number of points of a cluster (0 means no limit)")
+	flags.StringVar(&options.Sampler, "sampler", code.SAMPLER_UNIFORM, "sampler drawing the minimal samples: uniform, prosac (guided by quality scores) or napsac (neighbouring points)")
+	flags.StringVar(&options.ScoreAttribute, "score", code.DEFAULT_SCORE_ATTRIBUTE, "prosac: point attribute of the input file holding the quality scores")
+	flags.StringVar(&options.ScoreFile, "score-file", "", "prosac: file containing the quality scores, one per point and per line")
+	flags.Float64Var(&options.NapsacRadius, "napsac-radius", 0, "napsac: radius of the neighbourhood of the samples (0 uses 10 times eps)")
+	flags.Func("transform", "file holding a rigid transform applied to the point cloud first, a 4x4 matrix as text or JSON", func(s string) error {
+		transform, err := code.ReadTransform(s)
+		options.Transform = &transform
+		return err
+	})
+	flags.Float64Var(&options.EpsSigmaFactor, "eps-sigma", 0, "use this multiple of the noise estimated from the point cloud as eps instead of the eps argument")
+	flags.BoolVar(&options.Refit, "refit", false, "re-estimate each detected shape by least squares from all its supporting points")
+	flags.BoolVar(&options.LocalOptimization, "lo", false, "locally optimize promising hypotheses (LO-RANSAC)")
+	flags.BoolVar(&options.EarlyRejection, "sprt", false, "reject hypotheses unlikely to beat the best so far before checking all points (SPRT)")
+	err := flags.Parse(args)
+	if err != nil {
+		return options, err
+	}
+	// validate shape and method
+	if _, ok := code.GetModelFactory(options.Shape); !ok {
+		return options, fmt.Errorf("unknown shape: %s (available: %s)", options.Shape, strings.Join(code.RegisteredModels(), ", "))
+	}
+	if options.Method != code.METHOD_RANSAC && options.Method != code.METHOD_REGION_GROWING && options.Method != code.METHOD_JLINKAGE && options.Method != code.METHOD_MANHATTAN {
+		return options, fmt.Errorf("unknown method: %s", options.Method)
+	}
+	if options.MeshFormat != "" && options.MeshFormat != code.MESH_FORMAT_OBJ && options.MeshFormat != code.MESH_FORMAT_STL && options.MeshFormat != code.MESH_FORMAT_STL_BINARY && options.MeshFormat != code.MESH_FORMAT_PLY {
+		return options, fmt.Errorf("unknown mesh format: %s", options.MeshFormat)
+	}
+	if options.DEMImage != code.DEM_IMAGE_PGM && options.DEMImage != code.DEM_IMAGE_PNG {
+		return options, fmt.Errorf("unknown image format: %s", options.DEMImage)
+	}
+	if options.DEMCellSize > 0 && options.HeightPlane <= 0 {
+		return options, errors.New("-dem-cell requires -height-plane")
+	}
+	if options.Sampler != code.SAMPLER_UNIFORM && options.Sampler != code.SAMPLER_PROSAC && options.Sampler != code.SAMPLER_NAPSAC {
+		return options, fmt.Errorf("unknown sampler: %s", options.Sampler)
+	}
+	return options, nil
+}
+
+// method to parse the optional command line flags of the level command
+func parseLevelOptions(args []string) (code.LevelOptions, error) {
+	options := code.LevelOptions{}
+	flags := flag.NewFlagSet("level", flag.ContinueOnError)
+	flags.Float64Var(&options.MaxTilt, "max-tilt", 0, "maximum angle in degrees between the normal of the ground and the vertical (0 accepts any plane)")
+	flags.IntVar(&options.NormalNeighbours, "normal-k", code.DEFAULT_NORMAL_NEIGHBOURS, "number of neighbours used to estimate the noise")
+	flags.Func("transform", "file holding a rigid transform applied to the point cloud first, a 4x4 matrix as text or JSON", func(s string) error {
+		transform, err := code.ReadTransform(s)
+		options.Transform = &transform
+		return err
+	})
+	err := flags.Parse(args)
+	return options, err
+}
+
+// method to run the level command, which maps the ground plane of the point cloud to z = 0
+func level(args []string) {
+	if len(args) < 4 {
+		fmt.Println("Invalid number of arguments: ", len(args))
+		fmt.Println("Usage: ransac level <input file> <confidence> <percentage of points on plane> <eps|auto> [options]")
+		os.Exit(1)
+	}
+	autoEps := args[3] == "auto"
+	epsArgument := args[3]
+	if autoEps {
+		epsArgument = "0"
+	}
+	filename, confidence, percentageOfPointsOnPlane, eps, err := parseArguments(args[0], args[1], args[2], epsArgument)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	options, err := parseLevelOptions(args[4:])
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if autoEps {
+		options.EpsSigmaFactor = code.DEFAULT_EPS_SIGMA_FACTOR
+	}
+	code.Level(filename, confidence, percentageOfPointsOnPlane, eps, options)
+}
+
+// method to parse the optional command line flags of the register command
+func parseRegistrationOptions(args []string) (code.RegistrationOptions, error) {
+	options := code.RegistrationOptions{}
+	flags := flag.NewFlagSet("register", flag.ContinueOnError)
+	flags.StringVar(&options.ICP.Method, "method", code.ICP_POINT_TO_POINT, "ICP error metric: point (point to point) or plane (point to plane)")
+	flags.IntVar(&options.ICP.MaxIterations, "max-iterations", code.DEFAULT_ICP_ITERATIONS, "maximum number of ICP iterations")
+	flags.Float64Var(&options.ICP.Tolerance, "tolerance", code.DEFAULT_ICP_TOLERANCE, "change of the RMS error below which ICP has converged")
+	flags.Float64Var(&options.ICP.MaxDistance, "max-distance", 0, "maximum distance between corresponding points (0 means no limit)")
+	flags.IntVar(&options.ICP.Samples, "samples", code.DEFAULT_ICP_SAMPLES, "number of source points matched at each ICP iteration")
+	flags.IntVar(&options.ICP.NormalNeighbours, "normal-k", code.DEFAULT_NORMAL_NEIGHBOURS, "number of neighbours used to estimate the normals of the target points")
+	flags.Func("transform", "file holding the rigid transform ICP starts from, a 4x4 matrix as text or JSON", func(s string) error {
+		transform, err := code.ReadTransform(s)
+		options.ICP.Initial = &transform
+		return err
+	})
+	flags.BoolVar(&options.InitPlanes, "init-planes", false, "start ICP from the alignment of the dominant planes of the point clouds")
+	flags.IntVar(&options.NumOfPlanes, "n", code.DEFAULT_REGISTRATION_PLANES, "number of dominant planes matched by -init-planes")
+	flags.Float64Var(&options.PlaneEps, "plane-eps", 0, "eps of the detection of the dominant planes (0 uses 3 times the estimated noise)")
+	err := flags.Parse(args)
+	if err != nil {
+		return options, err
+	}
+	if options.ICP.Method != code.ICP_POINT_TO_POINT && options.ICP.Method != code.ICP_POINT_TO_PLANE {
+		return options, fmt.Errorf("unknown ICP method: %s", options.ICP.Method)
+	}
+	return options, nil
+}
+
+// method to run the register command, which aligns a source point cloud with a target point cloud
+func register(args []string) {
+	if len(args) < 4 {
+		fmt.Println("Invalid number of arguments: ", len(args))
+		fmt.Println("Usage: ransac register <source file> <target file> <confidence> <percentage of points on plane> [options]")
+		os.Exit(1)
+	}
+	// the confidence and the percentage of points on plane set the iterations of the detection of the dominant planes
+	// of -init-planes, whose eps is given by -plane-eps
+	source, confidence, percentageOfPointsOnPlane, _, err := parseArguments(args[0], args[2], args[3], "0")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	options, err := parseRegistrationOptions(args[4:])
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	code.Register(source, args[1], confidence, percentageOfPointsOnPlane, options)
+}
+
 func main() {
 
 	// if first argument is "test", run test
@@ -46,26 +278,59 @@ func main() {
 		os.Exit(0)
 	}
 
-	// main program must be supplied with 4 command line arguments
-	if len(os.Args) != 5 {
+	// if first argument is "level", level the point cloud
+	if len(os.Args) > 1 && os.Args[1] == "level" {
+		level(os.Args[2:])
+		os.Exit(0)
+	}
+
+	// if first argument is "register", register two point clouds
+	if len(os.Args) > 1 && os.Args[1] == "register" {
+		register(os.Args[2:])
+		os.Exit(0)
+	}
+
+	// main program must be supplied with 4 command line arguments, optionally followed by flags
+	if len(os.Args) < 5 {
 		fmt.Println("Invalid number of arguments: ", len(os.Args))
-		fmt.Println("Usage: ransac <input file> <confidence> <percentage of points on plane> <eps>")
+		fmt.Println("Usage: ransac <input file> <confidence> <percentage of points on plane> <eps|auto> [options]")
 		os.Exit(1)
 	}
 
+	// eps may be estimated from the point cloud
+	autoEps := os.Args[4] == "auto"
+	epsArgument := os.Args[4]
+	if autoEps {
+		epsArgument = "0"
+	}
+
 	// parse arguments
-	filename, confidence, percentageOfPointsOnPlane, eps, err := parseArguments(os.Args[1], os.Args[2], os.Args[3], os.Args[4])
+	filename, confidence, percentageOfPointsOnPlane, eps, err := parseArguments(os.Args[1], os.Args[2], os.Args[3], epsArgument)
 	// if error parsing arguments, print error and exit
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 
+	// parse optional flags
+	options, err := parseOptions(os.Args[5:])
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if autoEps && options.EpsSigmaFactor <= 0 {
+		options.EpsSigmaFactor = code.DEFAULT_EPS_SIGMA_FACTOR
+	}
+
 	fmt.Println("Parsing arguments completed successfully")
 	fmt.Println("Filename: ", filename)
 	fmt.Println("Confidence: ", confidence)
-	fmt.Println("Epsilon: ", eps)
+	if options.EpsSigmaFactor > 0 {
+		fmt.Println("Epsilon: ", options.EpsSigmaFactor, "times the estimated noise")
+	} else {
+		fmt.Println("Epsilon: ", eps)
+	}
 
 	// run RANSAC algorithm
-	code.RANSAC(filename, confidence, percentageOfPointsOnPlane, eps)
+	code.RANSAC(filename, confidence, percentageOfPointsOnPlane, eps, options)
 }
\ No newline at end of file
@@ -30,6 +30,15 @@ func TestRANSAC() {
 	percentageOfPointsOnPlane := 0.3
 	eps := 0.5
 
+	// plane segmentation methods and RANSAC samplers and verifiers to compare
+	runs := []code.RansacOptions{
+		{Method: code.METHOD_RANSAC, Sampler: code.SAMPLER_UNIFORM},
+		{Method: code.METHOD_RANSAC, Sampler: code.SAMPLER_UNIFORM, EarlyRejection: true},
+		{Method: code.METHOD_RANSAC, Sampler: code.SAMPLER_NAPSAC},
+		{Method: code.METHOD_REGION_GROWING},
+		{Method: code.METHOD_JLINKAGE},
+	}
+
 	/* End of Test RANSAC parameters */
 	/***********************************/
 
@@ -40,30 +49,56 @@ func TestRANSAC() {
 	fmt.Println("Confidence: ", confidence)
 	fmt.Println("Percentage of points on plane: ", percentageOfPointsOnPlane)
 	fmt.Println("Epsilon: ", eps)
+	fmt.Println("Methods: ", getRunNames(runs))
+
+	// store run times and number of points covered by dominant planes for each method and point cloud
+	runTimes := make([][]float64, len(runs))
+	covered := make([][]int, len(runs))
+
+	for m, options := range runs {
+		runTimes[m] = make([]float64, numPC)
+		covered[m] = make([]int, numPC)
 
-	// variable to alternate between point cloud
-	pc := 0
-
-	// store run times for each point cloud in a slice
-	runTimes := make([]float64, numPC)
-
-	// for number of tests to perform
-	for i := 0; i < n; i++ {
-		// record start time
-		start := time.Now()
-		// run RANSAC
-		code.TestRANSAC(pointCloudFiles[pc], confidence, percentageOfPointsOnPlane, eps)
-		// record run time
-		runTimes[pc] += time.Since(start).Seconds()
-		// alternate between point cloud
-		pc = (pc + 1) % numPC
+		// variable to alternate between point cloud
+		pc := 0
+
+		// for number of tests to perform
+		for i := 0; i < n; i++ {
+			// record start time
+			start := time.Now()
+			// run RANSAC
+			covered[m][pc] += code.TestRANSAC(pointCloudFiles[pc], confidence, percentageOfPointsOnPlane, eps, options)
+			// record run time
+			runTimes[m][pc] += time.Since(start).Seconds()
+			// alternate between point cloud
+			pc = (pc + 1) % numPC
+		}
 	}
 
-	// print average run times
-	fmt.Println("Average run times:")
-	for i := 0; i < numPC; i++ {
-		fmt.Println("PointCloud", i+1, ": ", runTimes[i]/float64(n/numPC))
+	// print average run times and number of points covered
+	for m, name := range getRunNames(runs) {
+		fmt.Println("Average run times and points covered by dominant planes (" + name + "):")
+		for i := 0; i < numPC; i++ {
+			fmt.Println("PointCloud", i+1, ": ", runTimes[m][i]/float64(n/numPC), covered[m][i]/(n/numPC))
+		}
 	}
 
 	fmt.Println("Test completed")
-}
\ No newline at end of file
+}
+
+// names of the compared runs, the segmentation method followed by the sampler and early rejection for RANSAC runs
+func getRunNames(runs []code.RansacOptions) []string {
+	names := []string{}
+	for _, options := range runs {
+		name := options.Method
+		if options.Method == code.METHOD_RANSAC {
+			name += " (" + options.Sampler + " sampling"
+			if options.EarlyRejection {
+				name += ", sprt"
+			}
+			name += ")"
+		}
+		names = append(names, name)
+	}
+	return names
+}